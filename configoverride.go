@@ -0,0 +1,185 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+// the code in this file supports overriding configuration settings from the command line, with
+// a repeatable --set section.key=value option. As flag defaults are computed from the
+// configuration when the flags are added, before the command line is parsed, the overrides
+// are found by scanning the raw command line arguments
+
+// ConfigurationOverrideFlag is the name of the flag that overrides configuration settings
+const ConfigurationOverrideFlag = "set"
+
+// AddConfigurationOverrideFlag adds the repeatable --set flag to a flag consumer (typically
+// a cobra command's persistent flags), so that the command line parser accepts it; the
+// overrides themselves are applied by ApplyConfigurationOverrides
+func AddConfigurationOverrideFlag(flags *pflag.FlagSet) {
+	flags.StringArray(
+		ConfigurationOverrideFlag,
+		nil,
+		"override a configuration setting, e.g., --set section.key=value; may be repeated",
+	)
+}
+
+// ApplyConfigurationOverrides applies the --set section.key=value options found in the
+// command line arguments (typically os.Args[1:]) to the configuration, as a layer on top of
+// the values read by ReadDefaultsConfigFile; call it before AddFlags. Sections may be nested,
+// as in --set outer.inner.key=value. A value's type is that of the corresponding flag, if the
+// key belongs to a FlagSet registered by AddDefaults; otherwise, the value is kept as a
+// string, which is converted to the type of the flag that uses it when the flag is added.
+// Problems are reported, and the return value is false if there were any
+func ApplyConfigurationOverrides(o output.Bus, c *Configuration, args []string) bool {
+	ok := true
+	for _, override := range findConfigurationOverrides(args) {
+		key, overrideErr := c.override(override)
+		if overrideErr != nil {
+			reportInvalidConfigurationOverride(o, override, overrideErr)
+			ok = false
+			continue
+		}
+		// the value is not logged, as it may be a secret
+		o.Log(output.Info, "configuration override", map[string]any{"key": key})
+	}
+	return ok
+}
+
+// findConfigurationOverrides finds the values of the --set options in the command line
+// arguments, ignoring any arguments following "--"
+func findConfigurationOverrides(args []string) []string {
+	var overrides []string
+	flagName := "--" + ConfigurationOverrideFlag
+	for k := 0; k < len(args); k++ {
+		arg := args[k]
+		switch {
+		case arg == "--":
+			return overrides
+		case arg == flagName:
+			if k+1 < len(args) {
+				k++
+				overrides = append(overrides, args[k])
+			} else {
+				overrides = append(overrides, "")
+			}
+		case strings.HasPrefix(arg, flagName+"="):
+			overrides = append(overrides, strings.TrimPrefix(arg, flagName+"="))
+		}
+	}
+	return overrides
+}
+
+// override applies a single section.key=value setting to the configuration, returning the
+// setting's section.key
+func (c *Configuration) override(setting string) (string, error) {
+	path, value, found := strings.Cut(setting, "=")
+	keys := strings.Split(path, ".")
+	if !found || len(keys) < 2 || slices.Contains(keys, "") {
+		return path, errors.New("it is not of the form section.key=value")
+	}
+	key := keys[len(keys)-1]
+	var details *FlagDetails
+	if set, registered := registeredFlagSets[keys[0]]; registered && len(keys) == 2 {
+		// an alias is replaced by the flag's name, so that the override takes precedence
+		// over a setting of the flag's name in the configuration file
+		key, details = set.detailsForKey(key)
+	}
+	typed, typeErr := overrideValue(details, value)
+	if typeErr != nil {
+		return path, typeErr
+	}
+	section := c
+	for _, name := range keys[:len(keys)-1] {
+		if section.ConfigurationMap == nil {
+			section.ConfigurationMap = map[string]*Configuration{}
+		}
+		sub, exists := section.ConfigurationMap[name]
+		if !exists {
+			sub = EmptyConfiguration()
+			section.ConfigurationMap[name] = sub
+		}
+		section = sub
+	}
+	section.replace(key, typed)
+	return path, nil
+}
+
+// overrideValue converts an override's value to the type of the corresponding flag; if there
+// is no such flag, the value remains a string, as guessing its type from its appearance would
+// hide it from a string flag. Configuration's IntDefault and BoolDefault parse strings, so a
+// string value serves a flag of any type
+func overrideValue(details *FlagDetails, value string) (any, error) {
+	if details == nil {
+		return value, nil
+	}
+	switch details.ExpectedType {
+	case BoolType:
+		b, parseErr := strconv.ParseBool(value)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%q is not a boolean value", value)
+		}
+		return b, nil
+	case IntType, CountType:
+		i, parseErr := strconv.Atoi(value)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%q is not an integer value", value)
+		}
+		return i, nil
+	default:
+		return value, nil
+	}
+}
+
+// detailsForKey finds the name and details of the flag that uses the configuration key,
+// either as its name or as one of its aliases; if there is no such flag, it returns the key
+// and nil details
+func (sf *FlagSet) detailsForKey(key string) (string, *FlagDetails) {
+	if details, found := sf.Details[key]; found {
+		return key, details
+	}
+	for name, details := range sf.Details {
+		if details != nil && slices.Contains(details.Aliases, key) {
+			return name, details
+		}
+	}
+	return key, nil
+}
+
+// replace sets the key's value, removing any value of another type that the key had
+func (c *Configuration) replace(key string, value any) {
+	delete(c.BoolMap, key)
+	delete(c.IntMap, key)
+	delete(c.StringMap, key)
+	switch v := value.(type) {
+	case bool:
+		if c.BoolMap == nil {
+			c.BoolMap = map[string]bool{}
+		}
+		c.BoolMap[key] = v
+	case int:
+		if c.IntMap == nil {
+			c.IntMap = map[string]int{}
+		}
+		c.IntMap[key] = v
+	default:
+		if c.StringMap == nil {
+			c.StringMap = map[string]string{}
+		}
+		c.StringMap[key] = fmt.Sprint(v)
+	}
+}
+
+func reportInvalidConfigurationOverride(o output.Bus, setting string, e error) {
+	o.ErrorPrintf("The configuration override --%s %q cannot be used: %s.\n", ConfigurationOverrideFlag, setting, e)
+	o.Log(output.Error, "invalid configuration override", map[string]any{
+		"setting": setting,
+		"error":   e,
+	})
+}
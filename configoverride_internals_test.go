@@ -0,0 +1,235 @@
+package cmd_toolkit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfigurationOverrides(t *testing.T) {
+	originalRegisteredFlagSets := registeredFlagSets
+	defer func() {
+		registeredFlagSets = originalRegisteredFlagSets
+	}()
+	registeredFlagSets = map[string]*FlagSet{}
+	registeredFlagSets["override"] = &FlagSet{
+		Name: "override",
+		Details: map[string]*FlagDetails{
+			"format": {
+				Usage:        "output format",
+				ExpectedType: StringType,
+				DefaultValue: "plain",
+				Aliases:      []string{"style"},
+			},
+			"limit": {
+				Usage:        "maximum entries",
+				ExpectedType: IntType,
+				DefaultValue: NewIntBounds(1, 10, 100),
+			},
+			"color": {
+				Usage:        "colorize output",
+				ExpectedType: BoolType,
+				DefaultValue: false,
+			},
+		},
+	}
+	newConfiguration := func() *Configuration {
+		return &Configuration{
+			ConfigurationMap: map[string]*Configuration{
+				"override": {
+					StringMap: map[string]string{"format": "json", "limit": "20"},
+					BoolMap:   map[string]bool{},
+					IntMap:    map[string]int{},
+				},
+			},
+		}
+	}
+	tests := map[string]struct {
+		args  []string
+		want  bool
+		wantC *Configuration
+		output.WantedRecording
+	}{
+		"no overrides": {
+			args:  []string{"--format", "csv"},
+			want:  true,
+			wantC: newConfiguration(),
+		},
+		"typed by flag": {
+			args: []string{"--set", "override.format=123", "--set=override.limit=50", "--set", "override.color=1"},
+			want: true,
+			wantC: &Configuration{
+				ConfigurationMap: map[string]*Configuration{
+					"override": {
+						StringMap: map[string]string{"format": "123"},
+						BoolMap:   map[string]bool{"color": true},
+						IntMap:    map[string]int{"limit": 50},
+					},
+				},
+			},
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info' key='override.format' msg='configuration override'\n" +
+					"level='info' key='override.limit' msg='configuration override'\n" +
+					"level='info' key='override.color' msg='configuration override'\n",
+			},
+		},
+		"alias replaced by flag name": {
+			args: []string{"--set", "override.style=csv"},
+			want: true,
+			wantC: &Configuration{
+				ConfigurationMap: map[string]*Configuration{
+					"override": {
+						StringMap: map[string]string{"format": "csv", "limit": "20"},
+						BoolMap:   map[string]bool{},
+						IntMap:    map[string]int{},
+					},
+				},
+			},
+			WantedRecording: output.WantedRecording{
+				Log: "level='info' key='override.style' msg='configuration override'\n",
+			},
+		},
+		"untyped": {
+			args: []string{
+				"--set", "other.n=5",
+				"--set", "other.b=false",
+				"--set", "other.s=hello=world",
+				"--set", "outer.inner.key=x",
+				"--", "--set", "ignored.key=1",
+			},
+			want: true,
+			wantC: &Configuration{
+				ConfigurationMap: map[string]*Configuration{
+					"override": newConfiguration().ConfigurationMap["override"],
+					"other": {
+						StringMap:        map[string]string{"n": "5", "b": "false", "s": "hello=world"},
+						BoolMap:          map[string]bool{},
+						IntMap:           map[string]int{},
+						ConfigurationMap: map[string]*Configuration{},
+					},
+					"outer": {
+						StringMap: map[string]string{},
+						BoolMap:   map[string]bool{},
+						IntMap:    map[string]int{},
+						ConfigurationMap: map[string]*Configuration{
+							"inner": {
+								StringMap:        map[string]string{"key": "x"},
+								BoolMap:          map[string]bool{},
+								IntMap:           map[string]int{},
+								ConfigurationMap: map[string]*Configuration{},
+							},
+						},
+					},
+				},
+			},
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info' key='other.n' msg='configuration override'\n" +
+					"level='info' key='other.b' msg='configuration override'\n" +
+					"level='info' key='other.s' msg='configuration override'\n" +
+					"level='info' key='outer.inner.key' msg='configuration override'\n",
+			},
+		},
+		"bad overrides": {
+			args: []string{
+				"--set", "override.limit=lots",
+				"--set", "override.color=maybe",
+				"--set", "nosection=1",
+				"--set", "override.format",
+				"--set",
+			},
+			want:  false,
+			wantC: newConfiguration(),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration override --set \"override.limit=lots\" cannot be used:" +
+					" \"lots\" is not an integer value.\n" +
+					"The configuration override --set \"override.color=maybe\" cannot be used:" +
+					" \"maybe\" is not a boolean value.\n" +
+					"The configuration override --set \"nosection=1\" cannot be used:" +
+					" it is not of the form section.key=value.\n" +
+					"The configuration override --set \"override.format\" cannot be used:" +
+					" it is not of the form section.key=value.\n" +
+					"The configuration override --set \"\" cannot be used:" +
+					" it is not of the form section.key=value.\n",
+				Log: "" +
+					"level='error' error='\"lots\" is not an integer value'" +
+					" setting='override.limit=lots' msg='invalid configuration override'\n" +
+					"level='error' error='\"maybe\" is not a boolean value'" +
+					" setting='override.color=maybe' msg='invalid configuration override'\n" +
+					"level='error' error='it is not of the form section.key=value'" +
+					" setting='nosection=1' msg='invalid configuration override'\n" +
+					"level='error' error='it is not of the form section.key=value'" +
+					" setting='override.format' msg='invalid configuration override'\n" +
+					"level='error' error='it is not of the form section.key=value'" +
+					" setting='' msg='invalid configuration override'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			c := newConfiguration()
+			if got := ApplyConfigurationOverrides(o, c, tt.args); got != tt.want {
+				t.Errorf("ApplyConfigurationOverrides() = %t, want %t", got, tt.want)
+			}
+			if !reflect.DeepEqual(c, tt.wantC) {
+				t.Errorf("ApplyConfigurationOverrides() configuration = %v, want %v", c, tt.wantC)
+			}
+			o.Report(t, "ApplyConfigurationOverrides()", tt.WantedRecording)
+		})
+	}
+}
+
+func TestAddConfigurationOverrideFlag(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddConfigurationOverrideFlag(flags)
+	if parseErr := flags.Parse([]string{"--set", "a.b=1", "--set=c.d=2"}); parseErr != nil {
+		t.Fatalf("Parse() failed: %v", parseErr)
+	}
+	got, _ := flags.GetStringArray(ConfigurationOverrideFlag)
+	if want := []string{"a.b=1", "c.d=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--set values = %v, want %v", got, want)
+	}
+}
+
+func TestApplyConfigurationOverrides_unregisteredFlagSet(t *testing.T) {
+	originalRegisteredFlagSets := registeredFlagSets
+	defer func() {
+		registeredFlagSets = originalRegisteredFlagSets
+	}()
+	registeredFlagSets = map[string]*FlagSet{}
+	set := &FlagSet{
+		Name: "unregistered",
+		Details: map[string]*FlagDetails{
+			"name":  {Usage: "a name", ExpectedType: StringType, DefaultValue: "none"},
+			"limit": {Usage: "a limit", ExpectedType: IntType, DefaultValue: NewIntBounds(1, 10, 100)},
+			"color": {Usage: "colorize", ExpectedType: BoolType, DefaultValue: false},
+		},
+	}
+	o := output.NewRecorder()
+	c := EmptyConfiguration()
+	args := []string{"--set", "unregistered.name=123", "--set", "unregistered.limit=50", "--set", "unregistered.color=true"}
+	if !ApplyConfigurationOverrides(o, c, args) {
+		t.Fatalf("ApplyConfigurationOverrides() failed")
+	}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddFlags(o, c, flags, set)
+	got, gotErrs := ReadFlags(flags, set)
+	if len(gotErrs) != 0 {
+		t.Fatalf("ReadFlags() errors = %v", gotErrs)
+	}
+	want := map[string]*CommandFlag[any]{
+		"name":  {Value: "123", Source: ConfigurationSource},
+		"limit": {Value: 50, Source: ConfigurationSource},
+		"color": {Value: true, Source: ConfigurationSource},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for k, v := range got {
+			t.Errorf("ReadFlags() got[%s] = %v, want %v", k, v, want[k])
+		}
+	}
+}
@@ -145,6 +145,19 @@ func (c *Configuration) StringDefault(key, defaultValue string) (string, error)
 	return dereferencedValue, nil
 }
 
+// hasKey returns whether a boolean, integer, or string value is defined for the specified
+// key
+func (c *Configuration) hasKey(key string) bool {
+	if _, found := c.BoolMap[key]; found {
+		return true
+	}
+	if _, found := c.IntMap[key]; found {
+		return true
+	}
+	_, found := c.StringMap[key]
+	return found
+}
+
 // stringValue returns the definition of the specified key and whether the value
 // is defined
 func (c *Configuration) stringValue(key string) (value string, found bool) {
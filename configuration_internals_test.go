@@ -100,3 +100,31 @@ func TestConfiguration_stringValue(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguration_hasKey(t *testing.T) {
+	c := &Configuration{
+		BoolMap:   map[string]bool{"b": true},
+		IntMap:    map[string]int{"i": 1},
+		StringMap: map[string]string{"s": "hello"},
+		ConfigurationMap: map[string]*Configuration{
+			"c": EmptyConfiguration(),
+		},
+	}
+	tests := map[string]struct {
+		key  string
+		want bool
+	}{
+		"bool":              {key: "b", want: true},
+		"int":               {key: "i", want: true},
+		"string":            {key: "s", want: true},
+		"sub-configuration": {key: "c", want: false},
+		"missing":           {key: "m", want: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := c.hasKey(tt.key); got != tt.want {
+				t.Errorf("Configuration.hasKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
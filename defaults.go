@@ -17,9 +17,11 @@ const (
 
 var (
 	defaultConfigurationSettings = map[string]map[string]any{}
+	registeredFlagSets           = map[string]*FlagSet{}
 )
 
-// AddDefaults copies data from a FlagSet into the map of default configuration settings
+// AddDefaults copies data from a FlagSet into the map of default configuration settings, and
+// registers the FlagSet for documentation purposes
 func AddDefaults(sf *FlagSet) {
 	if sf != nil && len(sf.Details) > 0 {
 		payload := map[string]any{}
@@ -36,6 +38,7 @@ func AddDefaults(sf *FlagSet) {
 			}
 		}
 		defaultConfigurationSettings[sf.Name] = payload
+		registeredFlagSets[sf.Name] = sf
 	}
 }
 
@@ -0,0 +1,139 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+// the code in this file supports renaming flags (the old names live on as aliases) and
+// deprecating them; each use of an alias or of a deprecated flag produces a warning, but only
+// once per process, so that a script setting the same flag repeatedly does not drown the user
+// in warnings
+
+var (
+	issuedFlagWarnings     = map[string]bool{}
+	issuedFlagWarningsLock = &sync.Mutex{}
+)
+
+// warningValue wraps a flag's value, issuing a warning when the value is set from the
+// command line
+type warningValue struct {
+	pflag.Value
+	warn func()
+}
+
+// Set issues the warning and then sets the wrapped value
+func (wv *warningValue) Set(s string) error {
+	wv.warn()
+	return wv.Value.Set(s)
+}
+
+func warnOnce(key string, warn func()) {
+	issuedFlagWarningsLock.Lock()
+	defer issuedFlagWarningsLock.Unlock()
+	if !issuedFlagWarnings[key] {
+		issuedFlagWarnings[key] = true
+		warn()
+	}
+}
+
+// addAliases registers the flag's aliases as hidden flags sharing the flag's value, and
+// arranges for warnings to be issued when the aliases, or the flag itself if it is
+// deprecated, are used on the command line
+func (fD *FlagDetails) addAliases(o output.Bus, consumer *pflag.FlagSet, flag flagParam) {
+	original := consumer.Lookup(flag.name)
+	if original == nil {
+		return
+	}
+	value := original.Value
+	if fD.Deprecated != "" {
+		original.Value = &warningValue{
+			Value: value,
+			warn: func() {
+				warnOnce(fmt.Sprintf("cli\x00%s\x00%s", flag.set, flag.name), func() {
+					reportDeprecatedFlag(o, flag, fD.Deprecated, "command line")
+				})
+			},
+		}
+	}
+	for _, alias := range fD.Aliases {
+		consumer.Var(&warningValue{
+			Value: value,
+			warn: func() {
+				warnOnce(fmt.Sprintf("cli\x00%s\x00%s", flag.set, alias), func() {
+					reportDeprecatedAlias(o, flag, alias, "command line")
+				})
+			},
+		}, alias, original.Usage)
+		aliasFlag := consumer.Lookup(alias)
+		aliasFlag.Hidden = true
+		aliasFlag.NoOptDefVal = original.NoOptDefVal
+	}
+}
+
+// configKey determines which key to use when looking up the flag's value in the
+// configuration: the flag's name, unless the configuration uses one of its aliases instead
+func (fD *FlagDetails) configKey(o output.Bus, c configSource, flag flagParam) string {
+	if c.hasKey(flag.name) {
+		if fD.Deprecated != "" {
+			warnOnce(fmt.Sprintf("config\x00%s\x00%s", flag.set, flag.name), func() {
+				reportDeprecatedFlag(o, flag, fD.Deprecated, defaultConfigFileName)
+			})
+		}
+		return flag.name
+	}
+	for _, alias := range fD.Aliases {
+		if c.hasKey(alias) {
+			warnOnce(fmt.Sprintf("config\x00%s\x00%s", flag.set, alias), func() {
+				reportDeprecatedAlias(o, flag, alias, defaultConfigFileName)
+			})
+			return alias
+		}
+	}
+	return flag.name
+}
+
+func reportDeprecatedAlias(o output.Bus, flag flagParam, alias, source string) {
+	switch source {
+	case defaultConfigFileName:
+		o.ErrorPrintf(
+			"The configuration file %q uses the deprecated key %q in %q; use %q instead.\n",
+			defaultConfigFileName,
+			alias,
+			flag.set,
+			flag.name,
+		)
+	default:
+		o.ErrorPrintf("The flag --%s is deprecated; use --%s instead.\n", alias, flag.name)
+	}
+	o.Log(output.Warning, "deprecated flag alias", map[string]any{
+		"set":    flag.set,
+		"flag":   flag.name,
+		"alias":  alias,
+		"source": source,
+	})
+}
+
+func reportDeprecatedFlag(o output.Bus, flag flagParam, hint, source string) {
+	switch source {
+	case defaultConfigFileName:
+		o.ErrorPrintf(
+			"The configuration file %q sets the deprecated flag %q in %q; %s.\n",
+			defaultConfigFileName,
+			flag.name,
+			flag.set,
+			hint,
+		)
+	default:
+		o.ErrorPrintf("The flag --%s is deprecated; %s.\n", flag.name, hint)
+	}
+	o.Log(output.Warning, "deprecated flag", map[string]any{
+		"set":    flag.set,
+		"flag":   flag.name,
+		"hint":   hint,
+		"source": source,
+	})
+}
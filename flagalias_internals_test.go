@@ -0,0 +1,189 @@
+package cmd_toolkit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+func Test_warnOnce(t *testing.T) {
+	originalIssuedFlagWarnings := issuedFlagWarnings
+	defer func() {
+		issuedFlagWarnings = originalIssuedFlagWarnings
+	}()
+	issuedFlagWarnings = map[string]bool{}
+	calls := 0
+	for range 3 {
+		warnOnce("key", func() { calls++ })
+	}
+	warnOnce("other key", func() { calls++ })
+	if calls != 2 {
+		t.Errorf("warnOnce() called warning function %d times, want 2", calls)
+	}
+}
+
+func TestFlagDetails_aliases(t *testing.T) {
+	originalIssuedFlagWarnings := issuedFlagWarnings
+	defer func() {
+		issuedFlagWarnings = originalIssuedFlagWarnings
+	}()
+	set := &FlagSet{
+		Name: "list",
+		Details: map[string]*FlagDetails{
+			"format": {
+				Usage:        "output format",
+				ExpectedType: StringType,
+				DefaultValue: "plain",
+				Aliases:      []string{"style"},
+			},
+			"annotate": {
+				Usage:        "annotate output",
+				ExpectedType: BoolType,
+				DefaultValue: false,
+				Aliases:      []string{"notes"},
+			},
+			"details": {
+				Usage:        "include details",
+				ExpectedType: BoolType,
+				DefaultValue: false,
+				Deprecated:   "use --annotate instead",
+			},
+		},
+	}
+	tests := map[string]struct {
+		config *Configuration
+		args   []string
+		want   map[string]*CommandFlag[any]
+		output.WantedRecording
+	}{
+		"no aliases used": {
+			config: EmptyConfiguration(),
+			args:   []string{"--format", "json", "--annotate"},
+			want: map[string]*CommandFlag[any]{
+				"annotate": {Value: true, UserSet: true, Source: CommandLineSource},
+				"details":  {Value: false},
+				"format":   {Value: "json", UserSet: true, Source: CommandLineSource},
+			},
+		},
+		"aliases used on the command line": {
+			config: EmptyConfiguration(),
+			args:   []string{"--style", "json", "--notes", "--style=csv", "--details"},
+			want: map[string]*CommandFlag[any]{
+				"annotate": {Value: true, UserSet: true, Source: CommandLineSource},
+				"details":  {Value: true, UserSet: true, Source: CommandLineSource},
+				"format":   {Value: "csv", UserSet: true, Source: CommandLineSource},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The flag --style is deprecated; use --format instead.\n" +
+					"The flag --notes is deprecated; use --annotate instead.\n" +
+					"The flag --details is deprecated; use --annotate instead.\n",
+				Log: "" +
+					"level='warning'" +
+					" alias='style'" +
+					" flag='format'" +
+					" set='list'" +
+					" source='command line'" +
+					" msg='deprecated flag alias'\n" +
+					"level='warning'" +
+					" alias='notes'" +
+					" flag='annotate'" +
+					" set='list'" +
+					" source='command line'" +
+					" msg='deprecated flag alias'\n" +
+					"level='warning'" +
+					" flag='details'" +
+					" hint='use --annotate instead'" +
+					" set='list'" +
+					" source='command line'" +
+					" msg='deprecated flag'\n",
+			},
+		},
+		"aliases used in the configuration file": {
+			config: &Configuration{
+				ConfigurationMap: map[string]*Configuration{
+					"list": {
+						BoolMap:   map[string]bool{"details": true},
+						StringMap: map[string]string{"style": "json"},
+					},
+				},
+			},
+			args: []string{},
+			want: map[string]*CommandFlag[any]{
+				"annotate": {Value: false},
+				"details":  {Value: true, Source: ConfigurationSource},
+				"format":   {Value: "json", Source: ConfigurationSource},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration file \"defaults.yaml\" sets the deprecated flag \"details\" in \"list\"; " +
+					"use --annotate instead.\n" +
+					"The configuration file \"defaults.yaml\" uses the deprecated key \"style\" in \"list\"; " +
+					"use \"format\" instead.\n",
+				Log: "" +
+					"level='warning'" +
+					" flag='details'" +
+					" hint='use --annotate instead'" +
+					" set='list'" +
+					" source='defaults.yaml'" +
+					" msg='deprecated flag'\n" +
+					"level='warning'" +
+					" alias='style'" +
+					" flag='format'" +
+					" set='list'" +
+					" source='defaults.yaml'" +
+					" msg='deprecated flag alias'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			issuedFlagWarnings = map[string]bool{}
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			AddFlags(o, tt.config, flags, set)
+			if parseErr := flags.Parse(tt.args); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			got, gotErrs := ReadFlags(flags, set)
+			if len(gotErrs) != 0 {
+				t.Errorf("ReadFlags() errors = %v", gotErrs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFlags() got = %v, want %v", got, tt.want)
+			}
+			if alias := flags.Lookup("style"); alias == nil || !alias.Hidden {
+				t.Errorf("AddFlags() did not register --style as a hidden flag")
+			}
+			o.Report(t, "aliases", tt.WantedRecording)
+		})
+	}
+}
+
+func TestFlagDetails_configKey(t *testing.T) {
+	originalIssuedFlagWarnings := issuedFlagWarnings
+	defer func() {
+		issuedFlagWarnings = originalIssuedFlagWarnings
+	}()
+	issuedFlagWarnings = map[string]bool{}
+	fD := &FlagDetails{Aliases: []string{"old", "older"}}
+	tests := map[string]struct {
+		keys []string
+		want string
+	}{
+		"nothing configured":   {keys: nil, want: "new"},
+		"name configured":      {keys: []string{"new", "old"}, want: "new"},
+		"alias configured":     {keys: []string{"older"}, want: "older"},
+		"both aliases present": {keys: []string{"older", "old"}, want: "old"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := fD.configKey(output.NewNilBus(), testConfigSource{keys: tt.keys}, flagParam{set: "s", name: "new"})
+			if got != tt.want {
+				t.Errorf("configKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
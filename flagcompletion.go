@@ -0,0 +1,104 @@
+package cmd_toolkit
+
+import (
+	"slices"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// the code in this file supports shell completion of flag values; AddFlags registers each
+// flag's completion hints with cobra, so that the completion scripts cobra generates for bash,
+// zsh, fish, and powershell offer sensible values for the flag
+
+// CompletionHints describes how a shell should complete a flag's value. At most one kind of
+// hint is used; in order of precedence, they are Dynamic, Values, DirectoriesOnly, and
+// FileExtensions. A flag with no hints gets the shell's default completion, file names, except
+// that a PathType flag whose Path.Kind is DirectoryPath completes directory names
+type CompletionHints struct {
+	// Values is a fixed list of the flag's acceptable values, e.g., the names of the
+	// supported output formats
+	Values []string
+	// FileExtensions restricts file name completion to files with any of the listed
+	// extensions, written without the leading period, e.g., "yaml"
+	FileExtensions []string
+	// DirectoriesOnly restricts completion to directory names
+	DirectoriesOnly bool
+	// Dynamic computes the completions when the user presses tab; toComplete is the partial
+	// value that the user has typed so far
+	Dynamic cobra.CompletionFunc
+}
+
+func (ch CompletionHints) empty() bool {
+	return ch.Dynamic == nil && len(ch.Values) == 0 && !ch.DirectoriesOnly && len(ch.FileExtensions) == 0
+}
+
+func (ch CompletionHints) copy() CompletionHints {
+	return CompletionHints{
+		Values:          slices.Clone(ch.Values),
+		FileExtensions:  slices.Clone(ch.FileExtensions),
+		DirectoriesOnly: ch.DirectoriesOnly,
+		Dynamic:         ch.Dynamic,
+	}
+}
+
+// completionHints returns the hints to apply to the flag, supplying a default for directory
+// flags that do not specify any
+func (fD *FlagDetails) completionHints() CompletionHints {
+	if fD.Completion.empty() && fD.ExpectedType == PathType && fD.Path.Kind == DirectoryPath {
+		return CompletionHints{DirectoriesOnly: true}
+	}
+	return fD.Completion
+}
+
+// addCompletion registers the flag's completion hints, and applies them to the flag's
+// aliases as well
+func (fD *FlagDetails) addCompletion(o output.Bus, consumer *pflag.FlagSet, flag flagParam) {
+	hints := fD.completionHints()
+	if hints.empty() {
+		return
+	}
+	for _, name := range append([]string{flag.name}, fD.Aliases...) {
+		if consumer.Lookup(name) == nil {
+			continue
+		}
+		var completionErr error
+		switch {
+		case hints.Dynamic != nil:
+			completionErr = registerFlagCompletionFunc(consumer, name, hints.Dynamic)
+		case len(hints.Values) > 0:
+			completionErr = registerFlagCompletionFunc(
+				consumer,
+				name,
+				cobra.FixedCompletions(hints.Values, cobra.ShellCompDirectiveNoFileComp),
+			)
+		case hints.DirectoriesOnly:
+			completionErr = cobra.MarkFlagDirname(consumer, name)
+		default:
+			completionErr = cobra.MarkFlagFilename(consumer, name, hints.FileExtensions...)
+		}
+		if completionErr != nil {
+			o.ErrorPrintf(
+				"An internal error occurred: completion for flag %q cannot be registered: %s.\n",
+				name,
+				ErrorToString(completionErr),
+			)
+			o.Log(output.Error, "internal error", map[string]any{
+				"set":   flag.set,
+				"flag":  name,
+				"error": completionErr,
+			})
+		}
+	}
+}
+
+// registerFlagCompletionFunc registers a completion function for a flag. Cobra keys its
+// completion functions by flag rather than by command, but only offers registration through a
+// command; as AddFlags is given the command's flags, and not the command itself, a temporary
+// command sharing the flag serves for the registration
+func registerFlagCompletionFunc(consumer *pflag.FlagSet, name string, fn cobra.CompletionFunc) error {
+	carrier := &cobra.Command{}
+	carrier.Flags().AddFlag(consumer.Lookup(name))
+	return carrier.RegisterFlagCompletionFunc(name, fn)
+}
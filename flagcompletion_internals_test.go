@@ -0,0 +1,48 @@
+package cmd_toolkit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlagDetails_completionHints(t *testing.T) {
+	tests := map[string]struct {
+		fD   *FlagDetails
+		want CompletionHints
+	}{
+		"string, no hints": {
+			fD:   &FlagDetails{ExpectedType: StringType},
+			want: CompletionHints{},
+		},
+		"string with hints": {
+			fD: &FlagDetails{
+				ExpectedType: StringType,
+				Completion:   CompletionHints{Values: []string{"a", "b"}},
+			},
+			want: CompletionHints{Values: []string{"a", "b"}},
+		},
+		"file path, no hints": {
+			fD:   &FlagDetails{ExpectedType: PathType, Path: PathRequirements{Kind: PlainFilePath}},
+			want: CompletionHints{},
+		},
+		"directory path, no hints": {
+			fD:   &FlagDetails{ExpectedType: PathType, Path: PathRequirements{Kind: DirectoryPath}},
+			want: CompletionHints{DirectoriesOnly: true},
+		},
+		"directory path with hints": {
+			fD: &FlagDetails{
+				ExpectedType: PathType,
+				Path:         PathRequirements{Kind: DirectoryPath},
+				Completion:   CompletionHints{FileExtensions: []string{"d"}},
+			},
+			want: CompletionHints{FileExtensions: []string{"d"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.fD.completionHints(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("completionHints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
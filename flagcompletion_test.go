@@ -0,0 +1,100 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+)
+
+func TestAddFlags_completion(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "list",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"format": {
+				Usage:        "output format",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				Aliases:      []string{"style"},
+				Completion:   cmdtoolkit.CompletionHints{Values: []string{"plain", "json", "yaml"}},
+			},
+			"album": {
+				Usage:        "album name",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Completion: cmdtoolkit.CompletionHints{
+					Dynamic: func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+						return []string{toComplete + "-live", toComplete + "-remastered"}, cobra.ShellCompDirectiveNoFileComp
+					},
+				},
+			},
+			"config": {
+				Usage:        "configuration file",
+				ExpectedType: cmdtoolkit.PathType,
+				DefaultValue: "",
+				Completion:   cmdtoolkit.CompletionHints{FileExtensions: []string{"yaml", "yml"}},
+			},
+			"music": {
+				Usage:        "music directory",
+				ExpectedType: cmdtoolkit.PathType,
+				DefaultValue: "",
+				Path:         cmdtoolkit.PathRequirements{Kind: cmdtoolkit.DirectoryPath},
+			},
+			"name": {
+				Usage:        "a name",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+			},
+		},
+	}
+	o := output.NewRecorder()
+	root := &cobra.Command{Use: "app", Run: func(_ *cobra.Command, _ []string) {}}
+	cmdtoolkit.AddFlags(o, cmdtoolkit.EmptyConfiguration(), root.Flags(), set)
+	o.Report(t, "AddFlags()", output.WantedRecording{})
+	tests := map[string]struct {
+		args []string
+		want string
+	}{
+		"fixed values": {
+			args: []string{"--format", ""},
+			want: "plain\njson\nyaml\n:4\n",
+		},
+		"fixed values via alias": {
+			args: []string{"--style", ""},
+			want: "plain\njson\nyaml\n:4\n",
+		},
+		"dynamic": {
+			args: []string{"--album", "abbey"},
+			want: "abbey-live\nabbey-remastered\n:4\n",
+		},
+		"file extensions": {
+			args: []string{"--config", ""},
+			want: "yaml\nyml\n:8\n",
+		},
+		"directory path": {
+			args: []string{"--music", ""},
+			want: ":16\n",
+		},
+		"no hints": {
+			args: []string{"--name", ""},
+			want: ":0\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			root.SetOut(stdout)
+			root.SetErr(&bytes.Buffer{})
+			root.SetArgs(append([]string{cobra.ShellCompRequestCmd}, tt.args...))
+			if executeErr := root.Execute(); executeErr != nil {
+				t.Fatalf("Execute() failed: %v", executeErr)
+			}
+			if got := stdout.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("completions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+// the code in this file generates reference documentation for FlagSets, so that an
+// application's documentation can be regenerated as part of its release process instead of
+// drifting out of date
+
+// DocumentationFormat specifies the format of generated flag documentation
+type DocumentationFormat int32
+
+const (
+	// MarkdownFormat generates Markdown documentation
+	MarkdownFormat DocumentationFormat = iota
+	// ManPageFormat generates man page documentation, written in roff
+	ManPageFormat
+)
+
+var valueTypeNames = map[valueType]string{
+	BoolType:   "bool",
+	IntType:    "int",
+	StringType: "string",
+	CountType:  "count",
+	PathType:   "path",
+}
+
+// flagDocumentation holds the description of a single flag
+type flagDocumentation struct {
+	name         string
+	abbreviation string
+	kind         string
+	usage        string
+	defaultValue string
+	bounds       string
+	configKey    string
+	envVars      []string
+	notes        []string
+}
+
+// RenderFlagSetDocumentation renders a reference page for a FlagSet in the specified format;
+// hidden flags are left out
+func RenderFlagSetDocumentation(applicationName string, set *FlagSet, format DocumentationFormat) string {
+	flags := describeFlags(set)
+	switch format {
+	case ManPageFormat:
+		return renderManPage(applicationName, set.Name, flags)
+	default:
+		return renderMarkdown(applicationName, set.Name, flags)
+	}
+}
+
+// WriteFlagSetDocumentation writes a reference page, in the specified format, for each
+// FlagSet registered by AddDefaults into the specified directory; the pages are named
+// <application name>-<FlagSet name>.md for Markdown and <application name>-<FlagSet name>.1
+// for man pages. Returns true if all the pages were written
+func WriteFlagSetDocumentation(o output.Bus, applicationName, dir string, format DocumentationFormat) bool {
+	extension := ".md"
+	if format == ManPageFormat {
+		extension = ".1"
+	}
+	names := make([]string, 0, len(registeredFlagSets))
+	for name := range registeredFlagSets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	ok := true
+	for _, name := range names {
+		fileName := filepath.Join(dir, fmt.Sprintf("%s-%s%s", applicationName, name, extension))
+		content := RenderFlagSetDocumentation(applicationName, registeredFlagSets[name], format)
+		if writeErr := afero.WriteFile(fileSystem, fileName, []byte(content), StdFilePermissions); writeErr != nil {
+			ReportFileCreationFailure(o, "documentation", fileName, writeErr)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func describeFlags(set *FlagSet) []flagDocumentation {
+	flags := make([]flagDocumentation, 0, len(set.Details))
+	for _, name := range sortedDetailNames(set.Details) {
+		details := set.Details[name]
+		if details == nil || details.Hidden {
+			continue
+		}
+		flags = append(flags, details.describe(set.Name, name))
+	}
+	return flags
+}
+
+func (fD *FlagDetails) describe(setName, name string) flagDocumentation {
+	doc := flagDocumentation{
+		name:         name,
+		abbreviation: fD.AbbreviatedName,
+		kind:         valueTypeNames[fD.ExpectedType],
+		usage:        fD.Usage,
+		defaultValue: fmt.Sprint(fD.DefaultValue),
+		configKey:    setName + "." + name,
+	}
+	switch value := fD.DefaultValue.(type) {
+	case *IntBounds:
+		if value != nil {
+			doc.defaultValue = fmt.Sprint(value.DefaultValue)
+			doc.bounds = describeBounds(value)
+		}
+	case string:
+		doc.defaultValue = fmt.Sprintf("%q", value)
+		doc.envVars = referencedEnvVars(value)
+	}
+	if fD.EnvVar != "" && !slices.Contains(doc.envVars, fD.EnvVar) {
+		doc.envVars = append([]string{fD.EnvVar}, doc.envVars...)
+	}
+	doc.notes = fD.notes(name)
+	return doc
+}
+
+func describeBounds(b *IntBounds) string {
+	switch {
+	case b.MinValue == math.MinInt && b.MaxValue == math.MaxInt:
+		return ""
+	case b.MinValue == math.MinInt:
+		return fmt.Sprintf("at most %d", b.MaxValue)
+	case b.MaxValue == math.MaxInt:
+		return fmt.Sprintf("at least %d", b.MinValue)
+	default:
+		return fmt.Sprintf("%d to %d", b.MinValue, b.MaxValue)
+	}
+}
+
+func referencedEnvVars(s string) []string {
+	refs := findReferences(s)
+	names := make([]string, len(refs))
+	for k, ref := range refs {
+		names[k] = strings.Trim(ref, "$%")
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func (fD *FlagDetails) notes(name string) []string {
+	var notes []string
+	if fD.Required {
+		notes = append(notes, "required")
+	}
+	if fD.Secret {
+		notes = append(notes, "prompted value is not echoed")
+	}
+	if fD.Deprecated != "" {
+		notes = append(notes, "deprecated; "+fD.Deprecated)
+	}
+	if fD.Experimental {
+		notes = append(notes, "experimental")
+	}
+	if len(fD.Aliases) > 0 {
+		aliases := make([]string, len(fD.Aliases))
+		for k, alias := range fD.Aliases {
+			aliases[k] = "--" + alias
+		}
+		notes = append(notes, "formerly "+strings.Join(aliases, ", "))
+	}
+	if fD.ExpectedType == BoolType && fD.Negatable {
+		notes = append(notes, "negate with --"+negatedFlagName(name))
+	}
+	if fD.ExpectedType == StringType && fD.FileValue {
+		notes = append(notes, "@path reads the value from a file, @- from stdin")
+	}
+	if fD.ExpectedType == PathType {
+		notes = append(notes, fD.Path.notes()...)
+	}
+	notes = append(notes, fD.Rules.notes()...)
+	return notes
+}
+
+func (pr PathRequirements) notes() []string {
+	var notes []string
+	if pr.MustExist {
+		notes = append(notes, "must exist")
+	}
+	switch pr.Kind {
+	case PlainFilePath:
+		notes = append(notes, "must be a plain file")
+	case DirectoryPath:
+		notes = append(notes, "must be a directory")
+	}
+	if pr.Creatable {
+		notes = append(notes, "must be creatable")
+	}
+	return notes
+}
+
+func renderMarkdown(applicationName, setName string, flags []flagDocumentation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s %s\n\n", applicationName, setName)
+	if len(flags) == 0 {
+		b.WriteString("There are no flags.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Default values may be changed in the `%s` section of `%s`.\n\n", setName, defaultConfigFileName)
+	b.WriteString("| Flag | Abbreviation | Type | Usage | Default | Bounds | Configuration key" +
+		" | Environment variables | Notes |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, flag := range flags {
+		abbreviation := ""
+		if flag.abbreviation != "" {
+			abbreviation = "`-" + flag.abbreviation + "`"
+		}
+		envVars := make([]string, len(flag.envVars))
+		for k, envVar := range flag.envVars {
+			envVars[k] = "`" + envVar + "`"
+		}
+		fmt.Fprintf(&b, "| `--%s` | %s | %s | %s | `%s` | %s | `%s` | %s | %s |\n",
+			flag.name,
+			abbreviation,
+			flag.kind,
+			escapeMarkdownCell(flag.usage),
+			flag.defaultValue,
+			flag.bounds,
+			flag.configKey,
+			strings.Join(envVars, ", "),
+			escapeMarkdownCell(strings.Join(flag.notes, "; ")),
+		)
+	}
+	return b.String()
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func renderManPage(applicationName, setName string, flags []flagDocumentation) string {
+	var b strings.Builder
+	title := strings.ToUpper(applicationName + "-" + setName)
+	fmt.Fprintf(&b, ".TH \"%s\" \"1\" \"\" \"%s\" \"%s\"\n", escapeRoff(title), escapeRoff(applicationName),
+		escapeRoff(applicationName))
+	b.WriteString(".SH NAME\n")
+	fmt.Fprintf(&b, "%s %s \\- command line flags\n", escapeRoff(applicationName), escapeRoff(setName))
+	b.WriteString(".SH OPTIONS\n")
+	if len(flags) == 0 {
+		b.WriteString("There are no flags.\n")
+	}
+	for _, flag := range flags {
+		b.WriteString(".TP\n")
+		if flag.abbreviation != "" {
+			fmt.Fprintf(&b, "\\fB%s\\fR, ", escapeRoff("-"+flag.abbreviation))
+		}
+		fmt.Fprintf(&b, "\\fB%s\\fR \\fI%s\\fR\n", escapeRoff("--"+flag.name), escapeRoff(flag.kind))
+		if flag.usage != "" {
+			fmt.Fprintf(&b, "%s\n", escapeRoffLine(flag.usage))
+		}
+		fmt.Fprintf(&b, ".br\nDefault: %s\n", escapeRoff(flag.defaultValue))
+		if flag.bounds != "" {
+			fmt.Fprintf(&b, ".br\nBounds: %s\n", escapeRoff(flag.bounds))
+		}
+		fmt.Fprintf(&b, ".br\nConfiguration key: %s\n", escapeRoff(flag.configKey))
+		if len(flag.envVars) > 0 {
+			fmt.Fprintf(&b, ".br\nEnvironment variables: %s\n", escapeRoff(strings.Join(flag.envVars, ", ")))
+		}
+		if len(flag.notes) > 0 {
+			fmt.Fprintf(&b, ".br\nNotes: %s\n", escapeRoff(strings.Join(flag.notes, "; ")))
+		}
+	}
+	b.WriteString(".SH FILES\n")
+	fmt.Fprintf(&b, "Default values may be changed in the \\fB%s\\fR section of \\fB%s\\fR.\n",
+		escapeRoff(setName), escapeRoff(defaultConfigFileName))
+	return b.String()
+}
+
+func escapeRoff(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// escapeRoffLine escapes text that begins a line, where a leading period or apostrophe
+// would be read as a request
+func escapeRoffLine(s string) string {
+	escaped := escapeRoff(s)
+	if strings.HasPrefix(escaped, ".") || strings.HasPrefix(escaped, "'") {
+		return `\&` + escaped
+	}
+	return escaped
+}
@@ -0,0 +1,89 @@
+package cmd_toolkit
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_describeBounds(t *testing.T) {
+	tests := map[string]struct {
+		b    *IntBounds
+		want string
+	}{
+		"unbounded":       {b: &IntBounds{MinValue: math.MinInt, MaxValue: math.MaxInt}, want: ""},
+		"upper bound":     {b: &IntBounds{MinValue: math.MinInt, MaxValue: 5}, want: "at most 5"},
+		"lower bound":     {b: &IntBounds{MinValue: -5, MaxValue: math.MaxInt}, want: "at least -5"},
+		"fully bounded":   {b: NewIntBounds(1, 2, 3), want: "1 to 3"},
+		"single value":    {b: NewIntBounds(2, 2, 2), want: "2 to 2"},
+		"negative bounds": {b: NewIntBounds(-3, -2, -1), want: "-3 to -1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := describeBounds(tt.b); got != tt.want {
+				t.Errorf("describeBounds() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_referencedEnvVars(t *testing.T) {
+	tests := map[string]struct {
+		s    string
+		want []string
+	}{
+		"none":       {s: "plain", want: []string{}},
+		"unix":       {s: "$HOME/$APP", want: []string{"APP", "HOME"}},
+		"windows":    {s: `%APPDATA%\%APP%`, want: []string{"APP", "APPDATA"}},
+		"duplicates": {s: "$APP/%APP%/$APP", want: []string{"APP"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := referencedEnvVars(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("referencedEnvVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagDetails_notes(t *testing.T) {
+	tests := map[string]struct {
+		fD   *FlagDetails
+		want []string
+	}{
+		"plain": {fD: &FlagDetails{ExpectedType: StringType}, want: nil},
+		"everything": {
+			fD: &FlagDetails{
+				ExpectedType: StringType,
+				Required:     true,
+				Secret:       true,
+				Deprecated:   "use --token instead",
+				Experimental: true,
+				Aliases:      []string{"pw", "pass"},
+				FileValue:    true,
+			},
+			want: []string{
+				"required",
+				"prompted value is not echoed",
+				"deprecated; use --token instead",
+				"experimental",
+				"formerly --pw, --pass",
+				"@path reads the value from a file, @- from stdin",
+			},
+		},
+		"path": {
+			fD: &FlagDetails{
+				ExpectedType: PathType,
+				Path:         PathRequirements{Kind: PlainFilePath, Creatable: true},
+			},
+			want: []string{"must be a plain file", "must be creatable"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.fD.notes("flag"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
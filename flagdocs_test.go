@@ -0,0 +1,171 @@
+package cmd_toolkit_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func documentedFlagSet() *cmdtoolkit.FlagSet {
+	return &cmdtoolkit.FlagSet{
+		Name: "list",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"format": {
+				AbbreviatedName: "f",
+				Usage:           "output format | style",
+				ExpectedType:    cmdtoolkit.StringType,
+				DefaultValue:    "plain",
+				Aliases:         []string{"style"},
+			},
+			"limit": {
+				Usage:        "maximum entries",
+				ExpectedType: cmdtoolkit.IntType,
+				DefaultValue: cmdtoolkit.NewIntBounds(1, 10, 100),
+			},
+			"music": {
+				Usage:        ".music directory",
+				ExpectedType: cmdtoolkit.PathType,
+				DefaultValue: "$HOME/Music",
+				Path:         cmdtoolkit.PathRequirements{MustExist: true, Kind: cmdtoolkit.DirectoryPath},
+			},
+			"color": {
+				Usage:        "colorize output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: true,
+				Negatable:    true,
+			},
+		},
+	}
+}
+
+func TestRenderFlagSetDocumentation(t *testing.T) {
+	tests := map[string]struct {
+		set    *cmdtoolkit.FlagSet
+		format cmdtoolkit.DocumentationFormat
+		want   string
+	}{
+		"markdown": {
+			set:    documentedFlagSet(),
+			format: cmdtoolkit.MarkdownFormat,
+			want: "" +
+				"# app list\n" +
+				"\n" +
+				"Default values may be changed in the `list` section of `defaults.yaml`.\n" +
+				"\n" +
+				"| Flag | Abbreviation | Type | Usage | Default | Bounds | Configuration key" +
+				" | Environment variables | Notes |\n" +
+				"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+				"| `--color` |  | bool | colorize output | `true` |  | `list.color` |  | negate with --no-color |\n" +
+				"| `--format` | `-f` | string | output format \\| style | `\"plain\"` |  | `list.format` |  |" +
+				" formerly --style |\n" +
+				"| `--limit` |  | int | maximum entries | `10` | 1 to 100 | `list.limit` |  |  |\n" +
+				"| `--music` |  | path | .music directory | `\"$HOME/Music\"` |  | `list.music` | `HOME` |" +
+				" must exist; must be a directory |\n",
+		},
+		"markdown, no flags": {
+			set: &cmdtoolkit.FlagSet{Name: "about", Details: map[string]*cmdtoolkit.FlagDetails{
+				"missing": nil,
+				"secret":  {Usage: "not for users", ExpectedType: cmdtoolkit.BoolType, DefaultValue: false, Hidden: true},
+			}},
+			format: cmdtoolkit.MarkdownFormat,
+			want:   "# app about\n\nThere are no flags.\n",
+		},
+		"man page": {
+			set:    documentedFlagSet(),
+			format: cmdtoolkit.ManPageFormat,
+			want: "" +
+				".TH \"APP\\-LIST\" \"1\" \"\" \"app\" \"app\"\n" +
+				".SH NAME\n" +
+				"app list \\- command line flags\n" +
+				".SH OPTIONS\n" +
+				".TP\n" +
+				"\\fB\\-\\-color\\fR \\fIbool\\fR\n" +
+				"colorize output\n" +
+				".br\nDefault: true\n" +
+				".br\nConfiguration key: list.color\n" +
+				".br\nNotes: negate with \\-\\-no\\-color\n" +
+				".TP\n" +
+				"\\fB\\-f\\fR, \\fB\\-\\-format\\fR \\fIstring\\fR\n" +
+				"output format | style\n" +
+				".br\nDefault: \"plain\"\n" +
+				".br\nConfiguration key: list.format\n" +
+				".br\nNotes: formerly \\-\\-style\n" +
+				".TP\n" +
+				"\\fB\\-\\-limit\\fR \\fIint\\fR\n" +
+				"maximum entries\n" +
+				".br\nDefault: 10\n" +
+				".br\nBounds: 1 to 100\n" +
+				".br\nConfiguration key: list.limit\n" +
+				".TP\n" +
+				"\\fB\\-\\-music\\fR \\fIpath\\fR\n" +
+				"\\&.music directory\n" +
+				".br\nDefault: \"$HOME/Music\"\n" +
+				".br\nConfiguration key: list.music\n" +
+				".br\nEnvironment variables: HOME\n" +
+				".br\nNotes: must exist; must be a directory\n" +
+				".SH FILES\n" +
+				"Default values may be changed in the \\fBlist\\fR section of \\fBdefaults.yaml\\fR.\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmdtoolkit.RenderFlagSetDocumentation("app", tt.set, tt.format); got != tt.want {
+				t.Errorf("RenderFlagSetDocumentation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFlagSetDocumentation(t *testing.T) {
+	originalFileSystem := cmdtoolkit.AssignFileSystem(afero.NewMemMapFs())
+	defer cmdtoolkit.AssignFileSystem(originalFileSystem)
+	cmdtoolkit.AddDefaults(documentedFlagSet())
+	_ = cmdtoolkit.FileSystem().Mkdir("docs", cmdtoolkit.StdDirPermissions)
+	tests := map[string]struct {
+		dir      string
+		format   cmdtoolkit.DocumentationFormat
+		want     bool
+		wantFile string
+	}{
+		"markdown": {
+			dir:      "docs",
+			format:   cmdtoolkit.MarkdownFormat,
+			want:     true,
+			wantFile: filepath.Join("docs", "app-list.md"),
+		},
+		"man page": {
+			dir:      "docs",
+			format:   cmdtoolkit.ManPageFormat,
+			want:     true,
+			wantFile: filepath.Join("docs", "app-list.1"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if got := cmdtoolkit.WriteFlagSetDocumentation(o, "app", tt.dir, tt.format); got != tt.want {
+				t.Errorf("WriteFlagSetDocumentation() = %t, want %t", got, tt.want)
+			}
+			content, readErr := afero.ReadFile(cmdtoolkit.FileSystem(), tt.wantFile)
+			if readErr != nil {
+				t.Errorf("WriteFlagSetDocumentation() did not write %q: %v", tt.wantFile, readErr)
+			} else if want := cmdtoolkit.RenderFlagSetDocumentation("app", documentedFlagSet(), tt.format); string(content) != want {
+				t.Errorf("WriteFlagSetDocumentation() wrote %q, want %q", content, want)
+			}
+			o.Report(t, "WriteFlagSetDocumentation()", output.WantedRecording{})
+		})
+	}
+	t.Run("unwritable", func(t *testing.T) {
+		cmdtoolkit.AssignFileSystem(afero.NewReadOnlyFs(afero.NewMemMapFs()))
+		o := output.NewRecorder()
+		if got := cmdtoolkit.WriteFlagSetDocumentation(o, "app", "docs", cmdtoolkit.MarkdownFormat); got {
+			t.Errorf("WriteFlagSetDocumentation() = %t, want false", got)
+		}
+		if o.ErrorOutput() == "" || o.LogOutput() == "" {
+			t.Errorf("WriteFlagSetDocumentation() did not report failures")
+		}
+	})
+}
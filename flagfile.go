@@ -0,0 +1,88 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// the code in this file supports reading string flag values from files (@path) or from stdin
+// (@-), which keeps long or sensitive values off the command line and out of shell history
+
+const (
+	defaultFileValueLimit = 1 << 20
+	fileValuePrefix       = "@"
+	stdinFileValue        = fileValuePrefix + "-"
+)
+
+// fileValueStdin exists to make it possible for unit tests to supply stdin content
+var fileValueStdin io.Reader = os.Stdin
+
+// resolveFileValue returns the content of the file referenced by the value, minus a single
+// trailing line ending; values that do not reference a file are returned as is
+func (fD *FlagDetails) resolveFileValue(name, value string, state *readState) (string, error) {
+	if !strings.HasPrefix(value, fileValuePrefix) {
+		return value, nil
+	}
+	if strings.HasPrefix(value, fileValuePrefix+fileValuePrefix) {
+		return value[len(fileValuePrefix):], nil
+	}
+	limit := fD.FileValueLimit
+	if limit <= 0 {
+		limit = defaultFileValueLimit
+	}
+	var content string
+	var readErr error
+	switch value {
+	case fileValuePrefix:
+		readErr = errors.New("no file name follows '@'")
+	case stdinFileValue:
+		if state.stdinRead {
+			readErr = errors.New("stdin has already been read for another flag")
+			break
+		}
+		state.stdinRead = true
+		content, readErr = readLimitedContent(fileValueStdin, limit)
+	default:
+		content, readErr = readFileValue(value[len(fileValuePrefix):], limit)
+	}
+	if readErr != nil {
+		return "", &FlagValueError{Flag: name, Value: value, Err: readErr}
+	}
+	return trimLineEnding(content), nil
+}
+
+func readFileValue(path string, limit int64) (string, error) {
+	if DirExists(path) {
+		return "", fmt.Errorf("%q is a directory", path)
+	}
+	f, openErr := fileSystem.Open(path)
+	if openErr != nil {
+		return "", openErr
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return readLimitedContent(f, limit)
+}
+
+func readLimitedContent(r io.Reader, limit int64) (string, error) {
+	// read one byte more than the limit to detect oversized content
+	content, readErr := io.ReadAll(io.LimitReader(r, limit+1))
+	if readErr != nil {
+		return "", readErr
+	}
+	if int64(len(content)) > limit {
+		return "", fmt.Errorf("the content exceeds the limit of %d bytes", limit)
+	}
+	return string(content), nil
+}
+
+func trimLineEnding(s string) string {
+	if trimmed, found := strings.CutSuffix(s, "\n"); found {
+		return strings.TrimSuffix(trimmed, "\r")
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFlagDetails_resolveFileValue(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalFileValueStdin := fileValueStdin
+	defer func() {
+		fileSystem = originalFileSystem
+		fileValueStdin = originalFileValueStdin
+	}()
+	fileSystem = afero.NewMemMapFs()
+	_ = fileSystem.Mkdir("dir", StdDirPermissions)
+	_ = afero.WriteFile(fileSystem, "template.txt", []byte("{{.Name}}\r\n"), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, "token", []byte("abc123"), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, "big", []byte(strings.Repeat("x", 11)), StdFilePermissions)
+	tests := map[string]struct {
+		fD        *FlagDetails
+		value     string
+		stdin     string
+		stdinRead bool
+		want      string
+		wantErr   error
+	}{
+		"plain value": {
+			fD:    &FlagDetails{},
+			value: "hello",
+			want:  "hello",
+		},
+		"escaped value": {
+			fD:    &FlagDetails{},
+			value: "@@home",
+			want:  "@home",
+		},
+		"file with line ending": {
+			fD:    &FlagDetails{},
+			value: "@template.txt",
+			want:  "{{.Name}}",
+		},
+		"file without line ending": {
+			fD:    &FlagDetails{},
+			value: "@token",
+			want:  "abc123",
+		},
+		"missing file": {
+			fD:    &FlagDetails{},
+			value: "@missing",
+			wantErr: &FlagValueError{
+				Flag:  "f",
+				Value: "@missing",
+				Err:   errors.New("open missing: file does not exist"),
+			},
+		},
+		"directory": {
+			fD:      &FlagDetails{},
+			value:   "@dir",
+			wantErr: &FlagValueError{Flag: "f", Value: "@dir", Err: errors.New(`"dir" is a directory`)},
+		},
+		"no file name": {
+			fD:      &FlagDetails{},
+			value:   "@",
+			wantErr: &FlagValueError{Flag: "f", Value: "@", Err: errors.New("no file name follows '@'")},
+		},
+		"file too big": {
+			fD:    &FlagDetails{FileValueLimit: 10},
+			value: "@big",
+			wantErr: &FlagValueError{
+				Flag:  "f",
+				Value: "@big",
+				Err:   errors.New("the content exceeds the limit of 10 bytes"),
+			},
+		},
+		"stdin": {
+			fD:    &FlagDetails{},
+			value: "@-",
+			stdin: "line 1\nline 2\n\n",
+			want:  "line 1\nline 2\n",
+		},
+		"stdin already read": {
+			fD:        &FlagDetails{},
+			value:     "@-",
+			stdin:     "data",
+			stdinRead: true,
+			wantErr: &FlagValueError{
+				Flag:  "f",
+				Value: "@-",
+				Err:   errors.New("stdin has already been read for another flag"),
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fileValueStdin = strings.NewReader(tt.stdin)
+			state := &readState{stdinRead: tt.stdinRead}
+			got, gotErr := tt.fD.resolveFileValue("f", tt.value, state)
+			if got != tt.want {
+				t.Errorf("resolveFileValue() got = %q, want %q", got, tt.want)
+			}
+			if gotErr != nil && tt.wantErr != nil {
+				if gotErr.Error() != tt.wantErr.Error() {
+					t.Errorf("resolveFileValue() gotErr = %v, want %v", gotErr, tt.wantErr)
+				}
+			} else if !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("resolveFileValue() gotErr = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_trimLineEnding(t *testing.T) {
+	tests := map[string]struct {
+		s    string
+		want string
+	}{
+		"empty":             {s: "", want: ""},
+		"no line ending":    {s: "abc", want: "abc"},
+		"unix line ending":  {s: "abc\n", want: "abc"},
+		"dos line ending":   {s: "abc\r\n", want: "abc"},
+		"two line endings":  {s: "abc\n\n", want: "abc\n"},
+		"bare return":       {s: "abc\r", want: "abc\r"},
+		"only line ending":  {s: "\n", want: ""},
+		"embedded newlines": {s: "a\nb\n", want: "a\nb"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := trimLineEnding(tt.s); got != tt.want {
+				t.Errorf("trimLineEnding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
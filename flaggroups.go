@@ -0,0 +1,198 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// the code in this file supports organizing a command's flags into named groups, each with its
+// own section in the command's usage text, and keeping experimental flags out of that usage
+// text unless the user asks for help on all the flags
+
+const (
+	flagGroupAnnotation        = "cmd_toolkit_flag_group"
+	experimentalFlagAnnotation = "cmd_toolkit_flag_experimental"
+	helpAllEnvVarAnnotation    = "cmd_toolkit_help_all_env_var"
+	helpAllFlagName            = "help-all"
+	groupedFlagUsagesFuncName  = "cmdToolkitGroupedFlagUsages"
+)
+
+// groupedUsageTemplate is cobra's default usage template, with its local flags section replaced
+// by grouped flag usages
+const groupedUsageTemplate = `Usage:{{if .Runnable}}
+  {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
+  {{.CommandPath}} [command]{{end}}{{if gt (len .Aliases) 0}}
+
+Aliases:
+  {{.NameAndAliases}}{{end}}{{if .HasExample}}
+
+Examples:
+{{.Example}}{{end}}{{if .HasAvailableSubCommands}}{{$cmds := .Commands}}{{if eq (len .Groups) 0}}
+
+Available Commands:{{range $cmds}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{else}}{{range $group := .Groups}}
+
+{{.Title}}{{range $cmds}}{{if (and (eq .GroupID $group.ID) (or .IsAvailableCommand (eq .Name "help")))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if not .AllChildCommandsHaveGroup}}
+
+Additional Commands:{{range $cmds}}{{if (and (eq .GroupID "") (or .IsAvailableCommand (eq .Name "help")))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
+
+{{` + groupedFlagUsagesFuncName + ` .}}{{end}}{{if .HasAvailableInheritedFlags}}
+
+Global Flags:
+{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasHelpSubCommands}}
+
+Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
+  {{rpad .CommandPath .CommandPathPadding}} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
+
+Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
+`
+
+// annotatePresentation records the flag's group and experimental status on the flag, where
+// GroupedFlagUsages can find them, and hides the flag from conventional usage text if it is
+// hidden or experimental
+func (fD *FlagDetails) annotatePresentation(consumer *pflag.FlagSet, flag flagParam) {
+	added := consumer.Lookup(flag.name)
+	if added == nil {
+		return
+	}
+	if fD.Group != "" {
+		_ = consumer.SetAnnotation(flag.name, flagGroupAnnotation, []string{fD.Group})
+	}
+	if fD.Experimental {
+		_ = consumer.SetAnnotation(flag.name, experimentalFlagAnnotation, []string{"true"})
+	}
+	added.Hidden = fD.Hidden || fD.Experimental
+}
+
+func flagGroup(f *pflag.Flag) string {
+	if group := f.Annotations[flagGroupAnnotation]; len(group) > 0 {
+		return group[0]
+	}
+	return ""
+}
+
+func experimentalFlag(f *pflag.Flag) bool {
+	return len(f.Annotations[experimentalFlagAnnotation]) > 0
+}
+
+// GroupedFlagUsages renders the usage text for a set of flags, in sections: first the flags
+// that belong to no group, under the heading "Flags:", then each group's flags, under the
+// heading "<group> Flags:", with the groups in alphabetical order. Hidden flags are omitted;
+// so are experimental flags, unless showAll is set
+func GroupedFlagUsages(flags *pflag.FlagSet, showAll bool) string {
+	groups := map[string]*pflag.FlagSet{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		visible := !f.Hidden
+		if experimentalFlag(f) {
+			visible = showAll
+		}
+		if !visible {
+			return
+		}
+		group := flagGroup(f)
+		if groups[group] == nil {
+			groups[group] = pflag.NewFlagSet(group, pflag.ContinueOnError)
+			groups[group].SortFlags = flags.SortFlags
+		}
+		shown := *f
+		shown.Hidden = false
+		groups[group].AddFlag(&shown)
+	})
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	// the unnamed group sorts first
+	slices.Sort(names)
+	sections := make([]string, 0, len(names))
+	for _, name := range names {
+		heading := "Flags:"
+		if name != "" {
+			heading = fmt.Sprintf("%s Flags:", name)
+		}
+		sections = append(sections, heading+"\n"+strings.TrimRight(groups[name].FlagUsages(), " \t\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// UseGroupedHelp arranges for a command, and its subcommands, to present their flags in
+// groups in their usage text, and adds a --help-all flag, which shows help including
+// experimental flags. If envVarName is not empty, the named environment variable, if set to
+// a true value (as interpreted by strconv.ParseBool), also shows experimental flags
+func UseGroupedHelp(cmd *cobra.Command, envVarName string) {
+	cobra.AddTemplateFunc(groupedFlagUsagesFuncName, groupedCommandFlagUsages)
+	cmd.SetUsageTemplate(groupedUsageTemplate)
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[helpAllEnvVarAnnotation] = envVarName
+	if cmd.PersistentFlags().Lookup(helpAllFlagName) == nil {
+		cmd.PersistentFlags().Bool(helpAllFlagName, false, "help for all flags, including experimental ones")
+		helpAll := cmd.PersistentFlags().Lookup(helpAllFlagName)
+		helpAll.Value = &helpAllValue{Value: helpAll.Value, root: cmd}
+	}
+}
+
+// helpAllValue is the value of the --help-all flag; setting it also sets the --help flag, so
+// that cobra shows the help text instead of running the command
+type helpAllValue struct {
+	pflag.Value
+	root *cobra.Command
+}
+
+// Set sets the value, and, if the value is true, requests help from whichever command is
+// executing
+func (hv *helpAllValue) Set(s string) error {
+	if setErr := hv.Value.Set(s); setErr != nil {
+		return setErr
+	}
+	if requested, _ := strconv.ParseBool(hv.Value.String()); requested {
+		requestHelp(hv.root)
+	}
+	return nil
+}
+
+// requestHelp sets the help flag on the command and all of its subcommands; as the flag is
+// set while the executing command's flags are parsed, and which command that is is not known,
+// setting the flag everywhere is the practical approach
+func requestHelp(cmd *cobra.Command) {
+	if help := cmd.Flags().Lookup("help"); help != nil {
+		_ = help.Value.Set("true")
+	}
+	for _, sub := range cmd.Commands() {
+		requestHelp(sub)
+	}
+}
+
+// groupedCommandFlagUsages renders a command's local flags for groupedUsageTemplate
+func groupedCommandFlagUsages(cmd *cobra.Command) string {
+	return GroupedFlagUsages(cmd.LocalFlags(), allHelpRequested(cmd))
+}
+
+// allHelpRequested determines whether the user has asked for help on all flags, by setting
+// --help-all or the environment variable specified to UseGroupedHelp
+func allHelpRequested(cmd *cobra.Command) bool {
+	if helpAll := cmd.Flag(helpAllFlagName); helpAll != nil {
+		if requested, _ := strconv.ParseBool(helpAll.Value.String()); requested {
+			return true
+		}
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if envVarName, ok := c.Annotations[helpAllEnvVarAnnotation]; ok {
+			if envVarName == "" {
+				return false
+			}
+			requested, _ := strconv.ParseBool(os.Getenv(envVarName))
+			return requested
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func groupedFlagSet() *cmdtoolkit.FlagSet {
+	return &cmdtoolkit.FlagSet{
+		Name: "list",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"format": {
+				AbbreviatedName: "f",
+				Usage:           "output format",
+				ExpectedType:    cmdtoolkit.StringType,
+				DefaultValue:    "plain",
+				Group:           "Output",
+			},
+			"color": {
+				Usage:        "colorize output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Group:        "Output",
+			},
+			"artist": {
+				Usage:        "artist filter",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: ".*",
+				Group:        "Filtering",
+			},
+			"turbo": {
+				Usage:        "use the experimental parallel scanner",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Group:        "Advanced",
+				Experimental: true,
+			},
+			"debug-dump": {
+				Usage:        "dump internal state",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Hidden:       true,
+			},
+			"verbose": {
+				Usage:        "verbose output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+			},
+		},
+	}
+}
+
+func TestGroupedFlagUsages(t *testing.T) {
+	tests := map[string]struct {
+		showAll bool
+		want    string
+	}{
+		"experimental flags hidden": {
+			showAll: false,
+			want: "" +
+				"Flags:\n" +
+				"      --verbose   verbose output (default false)\n" +
+				"\n" +
+				"Filtering Flags:\n" +
+				"      --artist string   artist filter (default \".*\")\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color           colorize output (default false)\n" +
+				"  -f, --format string   output format (default \"plain\")",
+		},
+		"all flags": {
+			showAll: true,
+			want: "" +
+				"Flags:\n" +
+				"      --verbose   verbose output (default false)\n" +
+				"\n" +
+				"Advanced Flags:\n" +
+				"      --turbo   use the experimental parallel scanner (default false) (experimental)\n" +
+				"\n" +
+				"Filtering Flags:\n" +
+				"      --artist string   artist filter (default \".*\")\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color           colorize output (default false)\n" +
+				"  -f, --format string   output format (default \"plain\")",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("list", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, cmdtoolkit.EmptyConfiguration(), flags, groupedFlagSet())
+			if got := cmdtoolkit.GroupedFlagUsages(flags, tt.showAll); got != tt.want {
+				t.Errorf("GroupedFlagUsages() = %q, want %q", got, tt.want)
+			}
+			if hidden := flags.Lookup("turbo"); hidden == nil || !hidden.Hidden {
+				t.Errorf("AddFlags() did not hide experimental flag --turbo")
+			}
+			o.Report(t, "GroupedFlagUsages()", output.WantedRecording{})
+		})
+	}
+}
+
+func TestUseGroupedHelp(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		envVarName string
+		envValue   string
+		want       string
+	}{
+		"help": {
+			args:       []string{"--help"},
+			envVarName: "CMD_TOOLKIT_TEST_HELP_ALL",
+			want: "" +
+				"Usage:\n" +
+				"  app [flags]\n" +
+				"\n" +
+				"Flags:\n" +
+				"  -h, --help       help for app\n" +
+				"      --help-all   help for all flags, including experimental ones\n" +
+				"      --verbose    verbose output (default false)\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color   colorize output (default false)\n",
+		},
+		"help all": {
+			args:       []string{"--help-all"},
+			envVarName: "CMD_TOOLKIT_TEST_HELP_ALL",
+			want: "" +
+				"Usage:\n" +
+				"  app [flags]\n" +
+				"\n" +
+				"Flags:\n" +
+				"  -h, --help       help for app\n" +
+				"      --help-all   help for all flags, including experimental ones\n" +
+				"      --verbose    verbose output (default false)\n" +
+				"\n" +
+				"Advanced Flags:\n" +
+				"      --turbo   use the experimental parallel scanner (default false) (experimental)\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color   colorize output (default false)\n",
+		},
+		"help with environment variable set": {
+			args:       []string{"--help"},
+			envVarName: "CMD_TOOLKIT_TEST_HELP_ALL",
+			envValue:   "true",
+			want: "" +
+				"Usage:\n" +
+				"  app [flags]\n" +
+				"\n" +
+				"Flags:\n" +
+				"  -h, --help       help for app\n" +
+				"      --help-all   help for all flags, including experimental ones\n" +
+				"      --verbose    verbose output (default false)\n" +
+				"\n" +
+				"Advanced Flags:\n" +
+				"      --turbo   use the experimental parallel scanner (default false) (experimental)\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color   colorize output (default false)\n",
+		},
+		"help with no environment variable": {
+			args: []string{"--help"},
+			want: "" +
+				"Usage:\n" +
+				"  app [flags]\n" +
+				"\n" +
+				"Flags:\n" +
+				"  -h, --help       help for app\n" +
+				"      --help-all   help for all flags, including experimental ones\n" +
+				"      --verbose    verbose output (default false)\n" +
+				"\n" +
+				"Output Flags:\n" +
+				"      --color   colorize output (default false)\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.envVarName != "" {
+				t.Setenv(tt.envVarName, tt.envValue)
+			}
+			ran := false
+			cmd := &cobra.Command{Use: "app", Run: func(_ *cobra.Command, _ []string) { ran = true }}
+			set := &cmdtoolkit.FlagSet{
+				Name: "app",
+				Details: map[string]*cmdtoolkit.FlagDetails{
+					"color":   groupedFlagSet().Details["color"],
+					"turbo":   groupedFlagSet().Details["turbo"],
+					"verbose": groupedFlagSet().Details["verbose"],
+				},
+			}
+			o := output.NewRecorder()
+			cmdtoolkit.AddFlags(o, cmdtoolkit.EmptyConfiguration(), cmd.Flags(), set)
+			cmdtoolkit.UseGroupedHelp(cmd, tt.envVarName)
+			stdout := &bytes.Buffer{}
+			cmd.SetOut(stdout)
+			cmd.SetArgs(tt.args)
+			if executeErr := cmd.Execute(); executeErr != nil {
+				t.Fatalf("Execute() failed: %v", executeErr)
+			}
+			if ran {
+				t.Errorf("Execute() ran the command instead of showing help")
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("help = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathKind specifies what kind of file system object a path flag's value must refer to
+type PathKind int32
+
+const (
+	// AnyPath accepts a path to any kind of file system object
+	AnyPath PathKind = iota
+	// PlainFilePath requires the path to refer to a plain file, not a directory
+	PlainFilePath
+	// DirectoryPath requires the path to refer to a directory
+	DirectoryPath
+)
+
+// PathRequirements describes the requirements that the value of a PathType flag must satisfy;
+// the requirements are checked against the file system returned by FileSystem()
+type PathRequirements struct {
+	// MustExist is set if the path must already exist
+	MustExist bool
+	// Kind specifies what the path must refer to, if it exists
+	Kind PathKind
+	// Creatable is set if a path that does not exist must be creatable, i.e., its parent
+	// must be an existing directory
+	Creatable bool
+}
+
+// userHomeDir exists to make it possible for unit tests to control ~ expansion
+var userHomeDir = os.UserHomeDir
+
+// resolve expands the path - dereferencing environment variables, replacing a leading ~ with
+// the user's home directory, and making it absolute - and checks it against the
+// requirements. An empty path is returned as is, without checking it
+func (pr PathRequirements) resolve(name, value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	path, expansionErr := expandPath(value)
+	if expansionErr != nil {
+		return "", &FlagValueError{Flag: name, Value: value, Err: expansionErr}
+	}
+	if checkErr := pr.check(path); checkErr != nil {
+		return "", &FlagValueError{Flag: name, Value: value, Err: checkErr}
+	}
+	return path, nil
+}
+
+func expandPath(value string) (string, error) {
+	path, dereferenceErr := DereferenceEnvVar(value)
+	if dereferenceErr != nil {
+		return "", dereferenceErr
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, homeErr := userHomeDir()
+		if homeErr != nil {
+			return "", fmt.Errorf("the home directory cannot be determined: %w", homeErr)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+func (pr PathRequirements) check(path string) error {
+	info, statErr := fileSystem.Stat(path)
+	switch {
+	case statErr == nil:
+		switch {
+		case pr.Kind == PlainFilePath && info.IsDir():
+			return fmt.Errorf("%q is a directory", path)
+		case pr.Kind == DirectoryPath && !info.IsDir():
+			return fmt.Errorf("%q is not a directory", path)
+		}
+		return nil
+	case errors.Is(statErr, fs.ErrNotExist):
+		if pr.MustExist {
+			return fmt.Errorf("%q does not exist", path)
+		}
+		if pr.Creatable {
+			if parent := filepath.Dir(path); !DirExists(parent) {
+				return fmt.Errorf("%q cannot be created because %q is not a directory", path, parent)
+			}
+		}
+		return nil
+	default:
+		return statErr
+	}
+}
@@ -0,0 +1,121 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestPathRequirements_resolve(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalUserHomeDir := userHomeDir
+	defer func() {
+		fileSystem = originalFileSystem
+		userHomeDir = originalUserHomeDir
+	}()
+	base, _ := filepath.Abs("pathTests")
+	home := filepath.Join(base, "home")
+	fileSystem = afero.NewMemMapFs()
+	_ = fileSystem.MkdirAll(filepath.Join(home, "music"), StdDirPermissions)
+	_ = afero.WriteFile(fileSystem, filepath.Join(home, "notes.txt"), []byte("notes"), StdFilePermissions)
+	userHomeDir = func() (string, error) { return home, nil }
+	t.Setenv("PATH_TESTS_HOME", home)
+	tests := map[string]struct {
+		pr      PathRequirements
+		value   string
+		home    func() (string, error)
+		want    string
+		wantErr string
+	}{
+		"empty": {
+			pr:    PathRequirements{MustExist: true},
+			value: "",
+			want:  "",
+		},
+		"relative path": {
+			pr:    PathRequirements{},
+			value: filepath.Join("pathTests", "home", "music"),
+			want:  filepath.Join(home, "music"),
+		},
+		"home directory": {
+			pr:    PathRequirements{MustExist: true, Kind: DirectoryPath},
+			value: "~",
+			want:  home,
+		},
+		"home-relative file": {
+			pr:    PathRequirements{MustExist: true, Kind: PlainFilePath},
+			value: "~/notes.txt",
+			want:  filepath.Join(home, "notes.txt"),
+		},
+		"environment variable": {
+			pr:    PathRequirements{MustExist: true, Kind: DirectoryPath},
+			value: filepath.Join("$PATH_TESTS_HOME", "music"),
+			want:  filepath.Join(home, "music"),
+		},
+		"undefined environment variable": {
+			pr:      PathRequirements{},
+			value:   "$PATH_TESTS_NO_SUCH_VARIABLE",
+			wantErr: "missing environment variables: [PATH_TESTS_NO_SUCH_VARIABLE]",
+		},
+		"unknown home directory": {
+			pr:      PathRequirements{},
+			value:   "~/music",
+			home:    func() (string, error) { return "", errors.New("$HOME is not defined") },
+			wantErr: "the home directory cannot be determined: $HOME is not defined",
+		},
+		"file required, directory found": {
+			pr:      PathRequirements{Kind: PlainFilePath},
+			value:   "~/music",
+			wantErr: `"` + filepath.Join(home, "music") + `" is a directory`,
+		},
+		"directory required, file found": {
+			pr:      PathRequirements{Kind: DirectoryPath},
+			value:   "~/notes.txt",
+			wantErr: `"` + filepath.Join(home, "notes.txt") + `" is not a directory`,
+		},
+		"must exist, does not": {
+			pr:      PathRequirements{MustExist: true},
+			value:   "~/missing",
+			wantErr: `"` + filepath.Join(home, "missing") + `" does not exist`,
+		},
+		"need not exist": {
+			pr:    PathRequirements{Kind: PlainFilePath},
+			value: "~/missing/file.txt",
+			want:  filepath.Join(home, "missing", "file.txt"),
+		},
+		"creatable": {
+			pr:    PathRequirements{Creatable: true},
+			value: "~/new.txt",
+			want:  filepath.Join(home, "new.txt"),
+		},
+		"not creatable": {
+			pr:    PathRequirements{Creatable: true},
+			value: "~/missing/new.txt",
+			wantErr: `"` + filepath.Join(home, "missing", "new.txt") + `" cannot be created because "` +
+				filepath.Join(home, "missing") + `" is not a directory`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			userHomeDir = func() (string, error) { return home, nil }
+			if tt.home != nil {
+				userHomeDir = tt.home
+			}
+			got, gotErr := tt.pr.resolve("path", tt.value)
+			if got != tt.want {
+				t.Errorf("PathRequirements.resolve() got = %q, want %q", got, tt.want)
+			}
+			switch {
+			case gotErr == nil && tt.wantErr != "":
+				t.Errorf("PathRequirements.resolve() gotErr = nil, want %q", tt.wantErr)
+			case gotErr != nil:
+				var valueErr *FlagValueError
+				if !errors.As(gotErr, &valueErr) || valueErr.Err.Error() != tt.wantErr {
+					t.Errorf("PathRequirements.resolve() gotErr = %v, want %q", gotErr, tt.wantErr)
+				}
+			}
+		})
+	}
+}
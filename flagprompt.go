@@ -0,0 +1,131 @@
+package cmd_toolkit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/majohn-r/output"
+	"golang.org/x/term"
+)
+
+// the vars declared below exist to make it possible for unit tests to exercise the prompting
+// code without a terminal
+var (
+	stdinRedirected              = stdinState
+	promptWriter       io.Writer = os.Stderr
+	readPromptedLine             = readLine
+	readPromptedSecret           = readSecret
+	promptReader       *bufio.Reader
+)
+
+// RequiredFlagError is returned by ReadFlags when a required flag was not set on the command
+// line and its value could not be obtained by prompting the user
+type RequiredFlagError struct {
+	// Flag is the name of the required flag
+	Flag string
+	// Err is the reason the user's response to the prompt could not be used; it is nil if
+	// the user could not be prompted because stdin is redirected
+	Err error
+}
+
+// Error generates a description of what happened
+func (e *RequiredFlagError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("required flag --%s was not set", e.Flag)
+	}
+	return fmt.Sprintf("required flag --%s was not set: %v", e.Flag, e.Err)
+}
+
+// Unwrap returns the reason the user's response to the prompt could not be used, if any
+func (e *RequiredFlagError) Unwrap() error {
+	return e.Err
+}
+
+func promptForFlag(name string, details *FlagDetails) (any, error) {
+	if stdinRedirected() {
+		return nil, &RequiredFlagError{Flag: name}
+	}
+	switch details.Usage {
+	case "":
+		_, _ = fmt.Fprintf(promptWriter, "Enter a value for --%s: ", name)
+	default:
+		_, _ = fmt.Fprintf(promptWriter, "Enter a value for --%s (%s): ", name, details.Usage)
+	}
+	var response string
+	var readErr error
+	switch details.Secret {
+	case true:
+		response, readErr = readPromptedSecret()
+		// the terminal does not echo the user's newline, either
+		_, _ = fmt.Fprintln(promptWriter)
+	case false:
+		response, readErr = readPromptedLine()
+	}
+	if readErr != nil {
+		return nil, &RequiredFlagError{Flag: name, Err: readErr}
+	}
+	if response == "" {
+		return nil, &RequiredFlagError{Flag: name, Err: errors.New("no value was entered")}
+	}
+	return interpretPromptedValue(name, details.ExpectedType, response)
+}
+
+func interpretPromptedValue(name string, expected valueType, response string) (any, error) {
+	switch expected {
+	case BoolType:
+		value, parseErr := strconv.ParseBool(response)
+		if parseErr != nil {
+			return nil, &RequiredFlagError{Flag: name, Err: fmt.Errorf("%q is not a boolean value", response)}
+		}
+		return value, nil
+	case IntType, CountType:
+		value, parseErr := strconv.Atoi(response)
+		if parseErr != nil {
+			return nil, &RequiredFlagError{Flag: name, Err: fmt.Errorf("%q is not an integer value", response)}
+		}
+		return value, nil
+	default:
+		return response, nil
+	}
+}
+
+func readLine() (string, error) {
+	// the reader is shared so that input buffered while reading one response is available
+	// for the next one
+	if promptReader == nil {
+		promptReader = bufio.NewReader(os.Stdin)
+	}
+	line, readErr := promptReader.ReadString('\n')
+	if readErr != nil && !(errors.Is(readErr, io.EOF) && line != "") {
+		return "", readErr
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func readSecret() (string, error) {
+	secret, readErr := term.ReadPassword(int(os.Stdin.Fd()))
+	return string(secret), readErr
+}
+
+func reportRequiredFlagError(o output.Bus, e *RequiredFlagError) {
+	switch e.Err {
+	case nil:
+		o.ErrorPrintf(
+			"The flag --%s is required, and its value cannot be requested because input has been redirected.\n",
+			e.Flag,
+		)
+		o.ErrorPrintln("What to do:")
+		o.ErrorPrintf("Set --%s on the command line.\n", e.Flag)
+	default:
+		o.ErrorPrintf("The flag --%s is required, and the value entered cannot be used: %s.\n", e.Flag, e.Err)
+	}
+	o.Log(output.Error, "required flag not set", map[string]any{
+		"flag":  e.Flag,
+		"error": e,
+	})
+}
@@ -0,0 +1,163 @@
+package cmd_toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_promptForFlag(t *testing.T) {
+	originalStdinRedirected := stdinRedirected
+	originalPromptWriter := promptWriter
+	originalReadPromptedLine := readPromptedLine
+	originalReadPromptedSecret := readPromptedSecret
+	defer func() {
+		stdinRedirected = originalStdinRedirected
+		promptWriter = originalPromptWriter
+		readPromptedLine = originalReadPromptedLine
+		readPromptedSecret = originalReadPromptedSecret
+	}()
+	type args struct {
+		name    string
+		details *FlagDetails
+	}
+	tests := map[string]struct {
+		redirected bool
+		response   string
+		readErr    error
+		args
+		want       any
+		wantErr    error
+		wantPrompt string
+	}{
+		"redirected": {
+			redirected: true,
+			args:       args{name: "user", details: &FlagDetails{ExpectedType: StringType, Required: true}},
+			wantErr:    &RequiredFlagError{Flag: "user"},
+		},
+		"string": {
+			response:   "jane",
+			args:       args{name: "user", details: &FlagDetails{Usage: "user name", ExpectedType: StringType}},
+			want:       "jane",
+			wantPrompt: "Enter a value for --user (user name): ",
+		},
+		"secret": {
+			response: "s3cr3t",
+			args: args{
+				name:    "password",
+				details: &FlagDetails{ExpectedType: StringType, Secret: true},
+			},
+			want:       "s3cr3t",
+			wantPrompt: "Enter a value for --password: \n",
+		},
+		"empty response": {
+			response:   "",
+			args:       args{name: "user", details: &FlagDetails{ExpectedType: StringType}},
+			wantErr:    &RequiredFlagError{Flag: "user", Err: errors.New("no value was entered")},
+			wantPrompt: "Enter a value for --user: ",
+		},
+		"read error": {
+			readErr:    errors.New("EOF"),
+			args:       args{name: "user", details: &FlagDetails{ExpectedType: StringType}},
+			wantErr:    &RequiredFlagError{Flag: "user", Err: errors.New("EOF")},
+			wantPrompt: "Enter a value for --user: ",
+		},
+		"int": {
+			response:   "42",
+			args:       args{name: "count", details: &FlagDetails{ExpectedType: IntType}},
+			want:       42,
+			wantPrompt: "Enter a value for --count: ",
+		},
+		"count": {
+			response:   "2",
+			args:       args{name: "verbose", details: &FlagDetails{ExpectedType: CountType}},
+			want:       2,
+			wantPrompt: "Enter a value for --verbose: ",
+		},
+		"bool": {
+			response:   "true",
+			args:       args{name: "force", details: &FlagDetails{ExpectedType: BoolType}},
+			want:       true,
+			wantPrompt: "Enter a value for --force: ",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			promptWriter = buffer
+			stdinRedirected = func() bool { return tt.redirected }
+			reader := func() (string, error) { return tt.response, tt.readErr }
+			readPromptedLine = reader
+			readPromptedSecret = reader
+			got, gotErr := promptForFlag(tt.args.name, tt.args.details)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("promptForFlag() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("promptForFlag() gotErr = %v, want %v", gotErr, tt.wantErr)
+			}
+			if gotPrompt := buffer.String(); gotPrompt != tt.wantPrompt {
+				t.Errorf("promptForFlag() prompt = %q, want %q", gotPrompt, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func Test_interpretPromptedValue(t *testing.T) {
+	type args struct {
+		expected valueType
+		response string
+	}
+	tests := map[string]struct {
+		args
+		want    any
+		wantErr error
+	}{
+		"string": {args: args{expected: StringType, response: "hello"}, want: "hello"},
+		"int":    {args: args{expected: IntType, response: "-3"}, want: -3},
+		"bad int": {
+			args:    args{expected: IntType, response: "three"},
+			wantErr: &RequiredFlagError{Flag: "f", Err: errors.New(`"three" is not an integer value`)},
+		},
+		"count": {args: args{expected: CountType, response: "2"}, want: 2},
+		"bad count": {
+			args:    args{expected: CountType, response: "vv"},
+			wantErr: &RequiredFlagError{Flag: "f", Err: errors.New(`"vv" is not an integer value`)},
+		},
+		"bool": {args: args{expected: BoolType, response: "F"}, want: false},
+		"bad bool": {
+			args:    args{expected: BoolType, response: "maybe"},
+			wantErr: &RequiredFlagError{Flag: "f", Err: errors.New(`"maybe" is not a boolean value`)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := interpretPromptedValue("f", tt.args.expected, tt.args.response)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("interpretPromptedValue() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("interpretPromptedValue() gotErr = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_readLine(t *testing.T) {
+	originalPromptReader := promptReader
+	defer func() {
+		promptReader = originalPromptReader
+	}()
+	promptReader = bufio.NewReader(strings.NewReader("first\r\nsecond\nlast"))
+	for _, want := range []string{"first", "second", "last"} {
+		if got, gotErr := readLine(); got != want || gotErr != nil {
+			t.Errorf("readLine() = %q, %v, want %q, nil", got, gotErr, want)
+		}
+	}
+	if got, gotErr := readLine(); got != "" || gotErr == nil {
+		t.Errorf("readLine() = %q, %v, want \"\", EOF", got, gotErr)
+	}
+}
@@ -0,0 +1,49 @@
+package cmd_toolkit_test
+
+import (
+	"errors"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+func TestRequiredFlagError_Error(t *testing.T) {
+	tests := map[string]struct {
+		e    *cmdtoolkit.RequiredFlagError
+		want string
+	}{
+		"redirected": {
+			e:    &cmdtoolkit.RequiredFlagError{Flag: "user"},
+			want: "required flag --user was not set",
+		},
+		"bad response": {
+			e:    &cmdtoolkit.RequiredFlagError{Flag: "user", Err: errors.New("no value was entered")},
+			want: "required flag --user was not set: no value was entered",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.e.Error(); got != tt.want {
+				t.Errorf("RequiredFlagError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredFlagError_Unwrap(t *testing.T) {
+	cause := errors.New("read failure")
+	tests := map[string]struct {
+		e    *cmdtoolkit.RequiredFlagError
+		want error
+	}{
+		"no cause": {e: &cmdtoolkit.RequiredFlagError{Flag: "user"}, want: nil},
+		"cause":    {e: &cmdtoolkit.RequiredFlagError{Flag: "user", Err: cause}, want: cause},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.e.Unwrap(); got != tt.want {
+				t.Errorf("RequiredFlagError.Unwrap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
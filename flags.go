@@ -1,9 +1,12 @@
 package cmd_toolkit
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"slices"
+	"strconv"
 
 	"github.com/majohn-r/output"
 	"github.com/spf13/pflag"
@@ -19,31 +22,86 @@ const (
 	IntType
 	// StringType represents a string flag type
 	StringType
+	// CountType represents a counted flag type, whose integer value is incremented each time
+	// the flag appears on the command line, e.g., -v -v -v or -vvv; the count starts from 0,
+	// replacing any value set by the configuration file or by an environment variable
+	CountType
+	// PathType represents a file system path flag type; ReadFlags expands its value and checks
+	// it against the flag's PathRequirements
+	PathType
 )
 
 type commandFlagValue interface {
 	string | int | bool | any
 }
 
-// CommandFlag captures a flag value and whether it was set by the user, i.e., on the command line
+// CommandFlag captures a flag value, whether it was set by the user, i.e., on the command
+// line, and where it came from
 type CommandFlag[V commandFlagValue] struct {
 	// Value is the flag's value
 	Value V
 	// UserSet is set if the flag value came from the command line
 	UserSet bool
+	// Source identifies where the flag value came from
+	Source FlagSource
 }
 
 // FlagDetails captures the data needed by the cobra command code: a flag's abbreviated name
-// (which may be empty), a usage string, its expected type, and its default value
+// (which may be empty), a usage string, its expected type, its default value, and whether
+// the user must provide a value for it
 type FlagDetails struct {
 	// AbbreviatedName is the flag's single character abbreviation, if any; typically empty
 	AbbreviatedName string
 	// Usage is a brief description of what the flag controls
 	Usage string
-	// ExpectedType describes whether the flag should be boolean, integer, or string
+	// ExpectedType describes whether the flag should be boolean, integer, string, counted, or
+	// a path
 	ExpectedType valueType
-	// DefaultValue gives the default value for the flag
+	// DefaultValue gives the default value for the flag: a bool for boolean flags, an
+	// *IntBounds for integer flags, a string for string and path flags, and an int for counted
+	// flags
 	DefaultValue any
+	// Required is set if the user must set the flag on the command line, through its
+	// environment variable, or in the configuration file; if the user does not, and stdin is
+	// a terminal, ReadFlags prompts the user for the value
+	Required bool
+	// Secret is set if the value is sensitive (e.g., a password): a prompted value is not
+	// echoed to the terminal, and the value is masked in error messages and in the log
+	Secret bool
+	// Aliases are former names of the flag; they are accepted, with a warning, on the
+	// command line and as keys in the configuration file
+	Aliases []string
+	// Deprecated, if not empty, marks the flag as deprecated and hints at what to use
+	// instead, e.g., "use --format instead"; using the flag produces a warning
+	Deprecated string
+	// Negatable is set if a boolean flag can also be set false with --no-<flag name>, which
+	// is useful when the configuration file sets the flag's default value to true
+	Negatable bool
+	// FileValue is set if a string flag's value may be read from a file, using @path, or from
+	// stdin, using @-; a value beginning with @@ is taken literally, minus the first @
+	FileValue bool
+	// FileValueLimit is the maximum number of bytes that may be read for a FileValue flag;
+	// if it is not positive, the limit is 1 MiB
+	FileValueLimit int64
+	// Path describes the requirements that the value of a PathType flag must satisfy
+	Path PathRequirements
+	// Completion provides hints for completing the flag's value in the user's shell
+	Completion CompletionHints
+	// Group, if not empty, names the section of the command's usage text in which the flag
+	// appears, e.g., "Output"; see GroupedFlagUsages
+	Group string
+	// Hidden is set if the flag is never to appear in the command's usage text
+	Hidden bool
+	// Experimental is set if the flag is to appear in the command's usage text only when the
+	// user asks for help on all flags; see UseGroupedHelp
+	Experimental bool
+	// Rules are the rules that the flag's value must satisfy when it comes from the
+	// configuration file, an environment variable, or the command line
+	Rules ValidationRules
+	// EnvVar, if not empty, names an environment variable that, if set, supplies the flag's
+	// value when the user does not set it on the command line; it takes precedence over the
+	// configuration file
+	EnvVar string
 }
 
 // Copy provides a copy of a FlagDetails instance - of primary use to test code.
@@ -53,10 +111,26 @@ func (fD *FlagDetails) Copy() *FlagDetails {
 		Usage:           fD.Usage,
 		ExpectedType:    fD.ExpectedType,
 		DefaultValue:    fD.DefaultValue,
+		Required:        fD.Required,
+		Secret:          fD.Secret,
+		Aliases:         slices.Clone(fD.Aliases),
+		Deprecated:      fD.Deprecated,
+		Negatable:       fD.Negatable,
+		FileValue:       fD.FileValue,
+		FileValueLimit:  fD.FileValueLimit,
+		Path:            fD.Path,
+		Completion:      fD.Completion.copy(),
+		Group:           fD.Group,
+		Hidden:          fD.Hidden,
+		Experimental:    fD.Experimental,
+		Rules:           fD.Rules,
+		EnvVar:          fD.EnvVar,
 	}
 }
 
 type configSource interface {
+	// hasKey determines whether a value is defined for the key
+	hasKey(string) bool
 	// BoolDefault provides a boolean default value
 	BoolDefault(string, bool) (bool, error)
 	// IntDefault provides an integer default value
@@ -67,18 +141,21 @@ type configSource interface {
 
 func (fD *FlagDetails) addFlag(o output.Bus, c configSource, consumer *pflag.FlagSet, flag flagParam) {
 	switch fD.ExpectedType {
-	case StringType:
+	case StringType, PathType:
 		statedDefault, _ok := fD.DefaultValue.(string)
 		if !_ok {
 			reportDefaultTypeError(o, flag.name, "string", fD.DefaultValue)
 			return
 		}
-		newDefault, malformedDefault := c.StringDefault(flag.name, statedDefault)
+		newDefault, malformedDefault := c.StringDefault(fD.configKey(o, c, flag), statedDefault)
 		if malformedDefault != nil {
 			reportInvalidConfigurationData(o, flag.set, malformedDefault)
 			return
 		}
-		usage := decorateStringFlagUsage(fD.Usage, newDefault)
+		if !fD.acceptsConfiguredDefault(o, c, flag, newDefault) {
+			return
+		}
+		usage := fD.decoratedUsage(decorateStringFlagUsage(fD.Usage, newDefault))
 		switch fD.AbbreviatedName {
 		case "":
 			consumer.String(flag.name, newDefault, usage)
@@ -91,12 +168,18 @@ func (fD *FlagDetails) addFlag(o output.Bus, c configSource, consumer *pflag.Fla
 			reportDefaultTypeError(o, flag.name, "bool", fD.DefaultValue)
 			return
 		}
-		newDefault, malformedDefault := c.BoolDefault(flag.name, statedDefault)
+		newDefault, malformedDefault := c.BoolDefault(fD.configKey(o, c, flag), statedDefault)
 		if malformedDefault != nil {
 			reportInvalidConfigurationData(o, flag.set, malformedDefault)
 			return
 		}
-		usage := decorateBoolFlagUsage(fD.Usage, newDefault)
+		if !fD.acceptsConfiguredDefault(o, c, flag, newDefault) {
+			return
+		}
+		usage := fD.decoratedUsage(decorateBoolFlagUsage(fD.Usage, newDefault))
+		if fD.Negatable {
+			usage = fmt.Sprintf("%s (negate with --%s)", usage, negatedFlagName(flag.name))
+		}
 		switch fD.AbbreviatedName {
 		case "":
 			consumer.Bool(flag.name, newDefault, usage)
@@ -109,18 +192,40 @@ func (fD *FlagDetails) addFlag(o output.Bus, c configSource, consumer *pflag.Fla
 			reportDefaultTypeError(o, flag.name, "*cmd_toolkit.IntBounds", fD.DefaultValue)
 			return
 		}
-		newDefault, malformedDefault := c.IntDefault(flag.name, bounds)
+		newDefault, malformedDefault := c.IntDefault(fD.configKey(o, c, flag), bounds)
 		if malformedDefault != nil {
 			reportInvalidConfigurationData(o, flag.set, malformedDefault)
 			return
 		}
-		usage := decorateIntFlagUsage(fD.Usage, newDefault)
+		if !fD.acceptsConfiguredDefault(o, c, flag, newDefault) {
+			return
+		}
+		usage := fD.decoratedUsage(decorateIntFlagUsage(fD.Usage, newDefault))
 		switch fD.AbbreviatedName {
 		case "":
 			consumer.Int(flag.name, newDefault, usage)
 		default:
 			consumer.IntP(flag.name, fD.AbbreviatedName, newDefault, usage)
 		}
+	case CountType:
+		statedDefault, _ok := fD.DefaultValue.(int)
+		if !_ok {
+			reportDefaultTypeError(o, flag.name, "int", fD.DefaultValue)
+			return
+		}
+		bounds := &IntBounds{MinValue: 0, DefaultValue: statedDefault, MaxValue: math.MaxInt}
+		newDefault, malformedDefault := c.IntDefault(fD.configKey(o, c, flag), bounds)
+		if malformedDefault != nil {
+			reportInvalidConfigurationData(o, flag.set, malformedDefault)
+			return
+		}
+		if !fD.acceptsConfiguredDefault(o, c, flag, newDefault) {
+			return
+		}
+		usage := fD.decoratedUsage(decorateIntFlagUsage(fD.Usage, newDefault))
+		value := &countedValue{count: newDefault}
+		added := consumer.VarPF(value, flag.name, fD.AbbreviatedName, usage)
+		added.NoOptDefVal = "+1"
 	default:
 		o.ErrorPrintf("An internal error occurred: unspecified flag type; set %q, flag %q.\n", flag.set, flag.name)
 		o.Log(output.Error, "internal error", map[string]any{
@@ -131,7 +236,177 @@ func (fD *FlagDetails) addFlag(o output.Bus, c configSource, consumer *pflag.Fla
 			"default-type":   reflect.TypeOf(fD.DefaultValue),
 			"error":          "unspecified flag type",
 		})
+		return
+	}
+	fD.annotatePresentation(consumer, flag)
+	fD.recordSource(o, c, consumer, flag)
+	if fD.ExpectedType == CountType {
+		startCounting(consumer, flag)
+	}
+	fD.addAliases(o, consumer, flag)
+	if fD.ExpectedType == BoolType && fD.Negatable {
+		fD.addNegation(o, consumer, flag)
+	}
+	fD.addCompletion(o, consumer, flag)
+}
+
+// countedValue is the value of a counted flag; unlike pflag's own count value, it counts the
+// flag's occurrences on the command line from 0, rather than from the default value set by
+// the configuration file or by an environment variable, which the command line replaces
+type countedValue struct {
+	count int
+	// counting is set once the command line has set the value
+	counting bool
+}
+
+// Set adds 1 to the count if s is "+1", which pflag passes for each occurrence of the flag
+// without a value, and otherwise sets the count to the value of s
+func (cv *countedValue) Set(s string) error {
+	if s == "+1" {
+		if !cv.counting {
+			cv.count = 0
+		}
+		cv.count++
+		cv.counting = true
+		return nil
+	}
+	n, parseErr := strconv.ParseInt(s, 0, 0)
+	if parseErr != nil {
+		return parseErr
+	}
+	cv.count = int(n)
+	cv.counting = true
+	return nil
+}
+
+// String returns the count
+func (cv *countedValue) String() string {
+	return strconv.Itoa(cv.count)
+}
+
+// Type returns the type of the value, which is always "count", as pflag's GetCount requires
+func (cv *countedValue) Type() string {
+	return "count"
+}
+
+// startCounting marks the counted flag's current value as its default, so that the first
+// occurrence of the flag on the command line replaces it
+func startCounting(consumer *pflag.FlagSet, flag flagParam) {
+	if added := consumer.Lookup(flag.name); added != nil {
+		if value, ok := added.Value.(*countedValue); ok {
+			value.counting = false
+			added.DefValue = value.String()
+		}
+	}
+}
+
+// negatedValue sets the value of a boolean flag to the opposite of its own value
+type negatedValue struct {
+	target pflag.Value
+}
+
+// Set sets the target flag's value to the opposite of the specified value
+func (nv *negatedValue) Set(s string) error {
+	value, parseErr := strconv.ParseBool(s)
+	if parseErr != nil {
+		return parseErr
+	}
+	return nv.target.Set(strconv.FormatBool(!value))
+}
+
+// String returns the opposite of the target flag's value
+func (nv *negatedValue) String() string {
+	value, _ := strconv.ParseBool(nv.target.String())
+	return strconv.FormatBool(!value)
+}
+
+// Type returns the type of the value, which is always "bool"
+func (nv *negatedValue) Type() string {
+	return "bool"
+}
+
+func negatedFlagName(name string) string {
+	return "no-" + name
+}
+
+// addNegation registers a hidden --no-<flag name> flag that sets the boolean flag to false
+func (fD *FlagDetails) addNegation(o output.Bus, consumer *pflag.FlagSet, flag flagParam) {
+	original := consumer.Lookup(flag.name)
+	if original == nil {
+		return
+	}
+	negatedName := negatedFlagName(flag.name)
+	if consumer.Lookup(negatedName) != nil {
+		o.ErrorPrintf(
+			"An internal error occurred: flag %q cannot be negated; flag %q already exists.\n",
+			flag.name,
+			negatedName,
+		)
+		o.Log(output.Error, "internal error", map[string]any{
+			"set":   flag.set,
+			"flag":  flag.name,
+			"error": "negated flag already exists",
+		})
+		return
 	}
+	consumer.Var(&negatedValue{target: original.Value}, negatedName, fmt.Sprintf("set --%s to false", flag.name))
+	negated := consumer.Lookup(negatedName)
+	negated.Hidden = true
+	negated.NoOptDefVal = "true"
+}
+
+// changed returns whether the flag was set on the command line, whether by name, by one of
+// its aliases, or by its negation
+func (fD *FlagDetails) changed(producer FlagProducer, name string) bool {
+	if producer.Changed(name) {
+		return true
+	}
+	for _, alias := range fD.Aliases {
+		if producer.Changed(alias) {
+			return true
+		}
+	}
+	return fD.ExpectedType == BoolType && fD.Negatable && producer.Changed(negatedFlagName(name))
+}
+
+// decoratedUsage adds the decorations common to all flag types to a flag's usage string;
+// a required flag's type-specific decorations are replaced, as its default value is of no
+// interest to the user
+func (fD *FlagDetails) decoratedUsage(usage string) string {
+	if fD.Required {
+		usage = fmt.Sprintf("%s (required)", fD.Usage)
+	}
+	if fD.Deprecated != "" {
+		usage = fmt.Sprintf("%s (deprecated; %s)", usage, fD.Deprecated)
+	}
+	if fD.Experimental {
+		usage = fmt.Sprintf("%s (experimental)", usage)
+	}
+	if fD.EnvVar != "" {
+		usage = fmt.Sprintf("%s (environment variable %s)", usage, fD.EnvVar)
+	}
+	return usage
+}
+
+// FlagValueError is returned by ReadFlags when a flag's value, whether set on the command
+// line or in the configuration file, cannot be used
+type FlagValueError struct {
+	// Flag is the name of the flag
+	Flag string
+	// Value is the flag's value as the user specified it
+	Value string
+	// Err is the reason the value cannot be used
+	Err error
+}
+
+// Error generates a description of what happened
+func (e *FlagValueError) Error() string {
+	return fmt.Sprintf("invalid value %q for flag --%s: %v", e.Value, e.Flag, e.Err)
+}
+
+// Unwrap returns the reason the value cannot be used
+func (e *FlagValueError) Unwrap() error {
+	return e.Err
 }
 
 // FlagSet captures a set of flags (typically, but not necessarily, associated with a cobra
@@ -154,6 +429,8 @@ type FlagProducer interface {
 	GetInt(name string) (int, error)
 	// GetString returns the string value of the named flag
 	GetString(name string) (string, error)
+	// GetCount returns the counted value of the named flag
+	GetCount(name string) (int, error)
 }
 
 type flagParam struct {
@@ -190,83 +467,57 @@ func AddFlags(o output.Bus, c *Configuration, flags *pflag.FlagSet, sets ...*Fla
 
 // GetBool gets the boolean value of a specific flag, handling common error conditions
 func GetBool(o output.Bus, results map[string]*CommandFlag[any], flagName string) (CommandFlag[bool], error) {
-	fv, flagNotFound := extractFlagValue(o, results, flagName)
-	if flagNotFound != nil {
-		return CommandFlag[bool]{}, flagNotFound
-	}
-	if fv == nil {
-		return CommandFlag[bool]{}, reportMissingFlagData(o, flagName)
-	}
-	v, ok := fv.Value.(bool)
-	if !ok {
-		return CommandFlag[bool]{}, reportIncorrectlyTypedValue(
-			o,
-			"a boolean",
-			flagName,
-			fv,
-		)
-	}
-	return CommandFlag[bool]{
-		Value:   v,
-		UserSet: fv.UserSet,
-	}, nil
+	return GetFlag[bool](o, results, flagName)
 }
 
 // GetInt gets the integer value of a specific flag, handling common error conditions
 func GetInt(o output.Bus, results map[string]*CommandFlag[any], flagName string) (CommandFlag[int], error) {
-	fv, flagNotFound := extractFlagValue(o, results, flagName)
-	if flagNotFound != nil {
-		return CommandFlag[int]{}, flagNotFound
-	}
-	if fv == nil {
-		return CommandFlag[int]{}, reportMissingFlagData(o, flagName)
-	}
-	v, ok := fv.Value.(int)
-	if !ok {
-		return CommandFlag[int]{}, reportIncorrectlyTypedValue(
-			o,
-			"an integer",
-			flagName,
-			fv,
-		)
-	}
-	return CommandFlag[int]{Value: v, UserSet: fv.UserSet}, nil
+	return GetFlag[int](o, results, flagName)
 }
 
 // GetString gets the string value of a specific flag, handling common error conditions
 func GetString(o output.Bus, results map[string]*CommandFlag[any], flagName string) (CommandFlag[string], error) {
-	fv, flagNotFound := extractFlagValue(o, results, flagName)
-	if flagNotFound != nil {
-		return CommandFlag[string]{}, flagNotFound
-	}
-	if fv == nil {
-		e := reportMissingFlagData(o, flagName)
-		return CommandFlag[string]{}, e
-	}
-	v, ok := fv.Value.(string)
-	if !ok {
-		return CommandFlag[string]{}, reportIncorrectlyTypedValue(
-			o,
-			"a string",
-			flagName,
-			fv,
-		)
-	}
-	return CommandFlag[string]{Value: v, UserSet: fv.UserSet}, nil
+	return GetFlag[string](o, results, flagName)
 }
 
 // ProcessFlagErrors handles a slice of errors; returns true iff the slice is empty
 func ProcessFlagErrors(o output.Bus, eSlice []error) bool {
 	if len(eSlice) != 0 {
 		for _, e := range eSlice {
-			o.ErrorPrintf("An internal error occurred: %s.\n", ErrorToString(e))
-			o.Log(output.Error, "internal error", map[string]any{"error": e})
+			var requiredErr *RequiredFlagError
+			var valueErr *FlagValueError
+			switch {
+			case errors.As(e, &requiredErr):
+				reportRequiredFlagError(o, requiredErr)
+			case errors.As(e, &valueErr):
+				reportFlagValueError(o, valueErr)
+			default:
+				o.ErrorPrintf("An internal error occurred: %s.\n", ErrorToString(e))
+				o.Log(output.Error, "internal error", map[string]any{"error": e})
+			}
 		}
 		return false
 	}
 	return true
 }
 
+// ExitErrorForFlagErrors translates a slice of errors, typically returned by ReadFlags, into
+// an ExitError for the named command: nil if the slice is empty, a user error if every error
+// is a RequiredFlagError or a FlagValueError, and a programming error otherwise
+func ExitErrorForFlagErrors(cmd string, eSlice []error) *ExitError {
+	if len(eSlice) == 0 {
+		return nil
+	}
+	for _, e := range eSlice {
+		var requiredErr *RequiredFlagError
+		var valueErr *FlagValueError
+		if !errors.As(e, &requiredErr) && !errors.As(e, &valueErr) {
+			return NewExitProgrammingError(cmd)
+		}
+	}
+	return NewExitUserError(cmd)
+}
+
 func sortedDetailNames(details map[string]*FlagDetails) []string {
 	sortedNames := make([]string, len(details))
 	index := 0
@@ -278,10 +529,13 @@ func sortedDetailNames(details map[string]*FlagDetails) []string {
 	return sortedNames
 }
 
-// ReadFlags reads the flags from a producer (typically a cobra commands flag structure)
+// ReadFlags reads the flags from a producer (typically a cobra commands flag structure);
+// the user is prompted for the values of required flags that were not set on the command
+// line, and the values of FileValue flags are read from the referenced files
 func ReadFlags(producer FlagProducer, set *FlagSet) (map[string]*CommandFlag[any], []error) {
 	m := map[string]*CommandFlag[any]{}
 	var e []error
+	state := &readState{}
 	// sort names for deterministic output in unit tests
 	sortedNames := sortedDetailNames(set.Details)
 	for _, name := range sortedNames {
@@ -291,19 +545,28 @@ func ReadFlags(producer FlagProducer, set *FlagSet) (map[string]*CommandFlag[any
 			continue
 		}
 		val := &CommandFlag[any]{
-			UserSet: producer.Changed(name),
+			UserSet: details.changed(producer, name),
+			Source:  defaultSource(producer, name),
 		}
 		var flagError error
 		switch details.ExpectedType {
 		case BoolType:
 			val.Value, flagError = producer.GetBool(name)
-		case StringType:
+		case StringType, PathType:
 			val.Value, flagError = producer.GetString(name)
 		case IntType:
 			val.Value, flagError = producer.GetInt(name)
+		case CountType:
+			val.Value, flagError = producer.GetCount(name)
 		default:
 			flagError = fmt.Errorf("unknown type for flag --%s", name)
 		}
+		if flagError == nil {
+			flagError = details.refineValue(name, val, state)
+		}
+		if val.UserSet {
+			val.Source = CommandLineSource
+		}
 		switch flagError {
 		case nil:
 			m[name] = val
@@ -314,6 +577,45 @@ func ReadFlags(producer FlagProducer, set *FlagSet) (map[string]*CommandFlag[any
 	return m, e
 }
 
+// readState holds state shared across the flags read by a single call to ReadFlags
+type readState struct {
+	// stdinRead is set once a flag's value has been read from stdin
+	stdinRead bool
+}
+
+// refineValue turns the value read from the producer into the flag's final value: prompting
+// the user for a required value that is missing from the command line, the environment,
+// and the configuration file, reading a FileValue flag's value from its file, resolving a
+// path flag's value, and checking the result against the flag's rules
+func (fD *FlagDetails) refineValue(name string, val *CommandFlag[any], state *readState) (err error) {
+	if fD.Required && !val.UserSet && val.Source == DefaultSource {
+		if val.Value, err = promptForFlag(name, fD); err != nil {
+			return
+		}
+		val.UserSet = true
+	}
+	// reference is the value as the user supplied it, before any file it names is read
+	reference := val.Value
+	switch fD.ExpectedType {
+	case StringType:
+		if fD.FileValue {
+			val.Value, err = fD.resolveFileValue(name, val.Value.(string), state)
+		}
+	case PathType:
+		val.Value, err = fD.Path.resolve(name, val.Value.(string))
+	}
+	if err == nil && (val.UserSet || val.Source != DefaultSource) {
+		if invalid := fD.validate(name, val.Value); invalid != nil {
+			if fD.FileValue {
+				// a file's content may be sensitive; the reference to the file is not
+				invalid.Value = fD.shownValue(reference)
+			}
+			err = invalid
+		}
+	}
+	return
+}
+
 func decorateBoolFlagUsage(usage string, defaultValue bool) string {
 	if defaultValue {
 		return usage
@@ -389,6 +691,15 @@ func reportIncorrectlyTypedValue(o output.Bus, expected, flagName string, fv *Co
 	return e
 }
 
+func reportFlagValueError(o output.Bus, e *FlagValueError) {
+	o.ErrorPrintf("The value %q for flag --%s cannot be used: %s.\n", e.Value, e.Flag, e.Err)
+	o.Log(output.Error, "invalid flag value", map[string]any{
+		"flag":  e.Flag,
+		"value": e.Value,
+		"error": e.Err,
+	})
+}
+
 func reportMissingFlagData(o output.Bus, flagName string) error {
 	e := fmt.Errorf("no data associated with flag")
 	o.ErrorPrintf("An internal error occurred: flag %q has no data.\n", flagName)
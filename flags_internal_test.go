@@ -2,6 +2,7 @@ package cmd_toolkit
 
 import (
 	"errors"
+	"slices"
 	"testing"
 
 	"github.com/majohn-r/output"
@@ -10,6 +11,11 @@ import (
 
 type testConfigSource struct {
 	generateError bool
+	keys          []string
+}
+
+func (tcs testConfigSource) hasKey(key string) bool {
+	return slices.Contains(tcs.keys, key)
 }
 
 func (tcs testConfigSource) BoolDefault(_ string, defaultValue bool) (bool, error) {
@@ -302,6 +308,112 @@ func TestFlagDetails_addFlag(t *testing.T) {
 			},
 			WantedRecording: output.WantedRecording{},
 		},
+		"bad count case: badly defined default": {
+			fD: &FlagDetails{
+				AbbreviatedName: "",
+				Usage:           "",
+				ExpectedType:    CountType,
+				DefaultValue:    &IntBounds{0, 1, 2},
+			},
+			args: args{
+				c:        nil,
+				consumer: &pflag.FlagSet{},
+				flag:     flagParam{set: "mySet", name: "myFlag"},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An internal error occurred: " +
+					"the type of flag \"myFlag\"'s value, '&{0 1 2}', is '*cmd_toolkit.IntBounds', " +
+					"but 'int' was expected.\n",
+				Log: "" +
+					"level='error'" +
+					" actual='*cmd_toolkit.IntBounds'" +
+					" error='default value mistyped'" +
+					" expected='int'" +
+					" flag='myFlag'" +
+					" value='&{0 1 2}'" +
+					" msg='internal error'\n",
+			},
+		},
+		"bad count case: badly configured default": {
+			fD: &FlagDetails{
+				AbbreviatedName: "",
+				Usage:           "",
+				ExpectedType:    CountType,
+				DefaultValue:    0,
+			},
+			args: args{
+				c:        testConfigSource{generateError: true},
+				consumer: &pflag.FlagSet{},
+				flag:     flagParam{set: "mySet", name: "myFlag"},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration file \"defaults.yaml\" contains an invalid value for \"mySet\": 'int error'.\n",
+				Log: "" +
+					"level='error'" +
+					" error='int error'" +
+					" section='mySet'" +
+					" msg='invalid content in configuration file'\n",
+			},
+		},
+		"good count case": {
+			fD: &FlagDetails{
+				AbbreviatedName: "",
+				Usage:           "",
+				ExpectedType:    CountType,
+				DefaultValue:    2,
+			},
+			args: args{
+				c:        testConfigSource{generateError: false},
+				consumer: &pflag.FlagSet{},
+				flag:     flagParam{set: "mySet", name: "myFlag"},
+			},
+			WantedRecording: output.WantedRecording{},
+		},
+		"good count case: abbreviated": {
+			fD: &FlagDetails{
+				AbbreviatedName: "m",
+				Usage:           "",
+				ExpectedType:    CountType,
+				DefaultValue:    0,
+			},
+			args: args{
+				c:        testConfigSource{generateError: false},
+				consumer: &pflag.FlagSet{},
+				flag:     flagParam{set: "mySet", name: "myFlag"},
+			},
+			WantedRecording: output.WantedRecording{},
+		},
+		"negatable bool case: negation already defined": {
+			fD: &FlagDetails{
+				AbbreviatedName: "",
+				Usage:           "",
+				ExpectedType:    BoolType,
+				DefaultValue:    true,
+				Negatable:       true,
+			},
+			args: args{
+				c: testConfigSource{generateError: false},
+				consumer: func() *pflag.FlagSet {
+					flags := &pflag.FlagSet{}
+					flags.Bool("no-myFlag", false, "")
+					return flags
+				}(),
+				flag: flagParam{set: "mySet", name: "myFlag"},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An internal error occurred: flag \"myFlag\" cannot be negated; " +
+					"flag \"no-myFlag\" already exists.\n",
+				Log: "" +
+					"level='error'" +
+					" error='negated flag already exists'" +
+					" flag='myFlag'" +
+					" set='mySet'" +
+					" msg='internal error'\n",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -374,3 +486,68 @@ func Test_decorateStringFlagUsage(t *testing.T) {
 		})
 	}
 }
+
+func TestFlagDetails_decoratedUsage(t *testing.T) {
+	tests := map[string]struct {
+		fD    *FlagDetails
+		usage string
+		want  string
+	}{
+		"optional": {
+			fD:    &FlagDetails{Usage: "set magic flag"},
+			usage: "set magic flag (default false)",
+			want:  "set magic flag (default false)",
+		},
+		"required": {
+			fD:    &FlagDetails{Usage: "set magic flag", Required: true},
+			usage: "set magic flag (default false)",
+			want:  "set magic flag (required)",
+		},
+		"deprecated": {
+			fD:    &FlagDetails{Usage: "set magic flag", Deprecated: "use --magic instead"},
+			usage: "set magic flag (default false)",
+			want:  "set magic flag (default false) (deprecated; use --magic instead)",
+		},
+		"experimental": {
+			fD:    &FlagDetails{Usage: "set magic flag", Experimental: true},
+			usage: "set magic flag (default false)",
+			want:  "set magic flag (default false) (experimental)",
+		},
+		"environment variable": {
+			fD:    &FlagDetails{Usage: "set magic flag", EnvVar: "MAGIC"},
+			usage: "set magic flag (default false)",
+			want:  "set magic flag (default false) (environment variable MAGIC)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.fD.decoratedUsage(tt.usage); got != tt.want {
+				t.Errorf("decoratedUsage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_negatedValue(t *testing.T) {
+	flags := &pflag.FlagSet{}
+	flags.Bool("flag", true, "")
+	nv := &negatedValue{target: flags.Lookup("flag").Value}
+	if got := nv.Type(); got != "bool" {
+		t.Errorf("negatedValue.Type() = %q, want %q", got, "bool")
+	}
+	if got := nv.String(); got != "false" {
+		t.Errorf("negatedValue.String() = %q, want %q", got, "false")
+	}
+	if err := nv.Set("maybe"); err == nil {
+		t.Errorf("negatedValue.Set(\"maybe\") succeeded")
+	}
+	if err := nv.Set("true"); err != nil {
+		t.Errorf("negatedValue.Set(\"true\") failed: %v", err)
+	}
+	if got, _ := flags.GetBool("flag"); got {
+		t.Errorf("negatedValue.Set(\"true\") left target true")
+	}
+	if got := nv.String(); got != "true" {
+		t.Errorf("negatedValue.String() = %q, want %q", got, "true")
+	}
+}
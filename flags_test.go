@@ -2,6 +2,7 @@ package cmd_toolkit_test
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -57,6 +58,156 @@ func TestFlagDetails_Copy(t *testing.T) {
 				DefaultValue:    "hello!",
 			},
 		},
+		"required secret": {
+			fD: &cmdtoolkit.FlagDetails{
+				AbbreviatedName: "p",
+				Usage:           "the password",
+				ExpectedType:    cmdtoolkit.StringType,
+				DefaultValue:    "",
+				Required:        true,
+				Secret:          true,
+			},
+			want: &cmdtoolkit.FlagDetails{
+				AbbreviatedName: "p",
+				Usage:           "the password",
+				ExpectedType:    cmdtoolkit.StringType,
+				DefaultValue:    "",
+				Required:        true,
+				Secret:          true,
+			},
+		},
+		"renamed and deprecated": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "output style",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				Aliases:      []string{"style", "fmt"},
+				Deprecated:   "use --format instead",
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "output style",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				Aliases:      []string{"style", "fmt"},
+				Deprecated:   "use --format instead",
+			},
+		},
+		"negatable": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "colorize output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: true,
+				Negatable:    true,
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "colorize output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: true,
+				Negatable:    true,
+			},
+		},
+		"path": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "output directory",
+				ExpectedType: cmdtoolkit.PathType,
+				DefaultValue: "~/out",
+				Path: cmdtoolkit.PathRequirements{
+					Kind:      cmdtoolkit.DirectoryPath,
+					Creatable: true,
+				},
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "output directory",
+				ExpectedType: cmdtoolkit.PathType,
+				DefaultValue: "~/out",
+				Path: cmdtoolkit.PathRequirements{
+					Kind:      cmdtoolkit.DirectoryPath,
+					Creatable: true,
+				},
+			},
+		},
+		"file value": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:          "output template",
+				ExpectedType:   cmdtoolkit.StringType,
+				DefaultValue:   "",
+				FileValue:      true,
+				FileValueLimit: 4096,
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:          "output template",
+				ExpectedType:   cmdtoolkit.StringType,
+				DefaultValue:   "",
+				FileValue:      true,
+				FileValueLimit: 4096,
+			},
+		},
+		"completion": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "output format",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				Completion: cmdtoolkit.CompletionHints{
+					Values:         []string{"plain", "json"},
+					FileExtensions: []string{"yaml"},
+				},
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "output format",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				Completion: cmdtoolkit.CompletionHints{
+					Values:         []string{"plain", "json"},
+					FileExtensions: []string{"yaml"},
+				},
+			},
+		},
+		"grouped": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "use the parallel scanner",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Group:        "Advanced",
+				Hidden:       true,
+				Experimental: true,
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "use the parallel scanner",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Group:        "Advanced",
+				Hidden:       true,
+				Experimental: true,
+			},
+		},
+		"environment variable": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "access token",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				EnvVar:       "TOKEN",
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "access token",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				EnvVar:       "TOKEN",
+			},
+		},
+		"rules": {
+			fD: &cmdtoolkit.FlagDetails{
+				Usage:        "server URL",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Rules:        cmdtoolkit.ValidationRules{MaxLength: 80, Format: cmdtoolkit.URLFormat},
+			},
+			want: &cmdtoolkit.FlagDetails{
+				Usage:        "server URL",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Rules:        cmdtoolkit.ValidationRules{MaxLength: 80, Format: cmdtoolkit.URLFormat},
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -456,6 +607,46 @@ func TestProcessFlagErrors(t *testing.T) {
 				Log:   "level='error' error='some error' msg='internal error'\n",
 			},
 		},
+		"required flag errors": {
+			eSlice: []error{
+				&cmdtoolkit.RequiredFlagError{Flag: "user"},
+				&cmdtoolkit.RequiredFlagError{Flag: "count", Err: errors.New(`"x" is not an integer value`)},
+			},
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The flag --user is required, and its value cannot be requested because input has been " +
+					"redirected.\n" +
+					"What to do:\n" +
+					"Set --user on the command line.\n" +
+					"The flag --count is required, and the value entered cannot be used: " +
+					"\"x\" is not an integer value.\n",
+				Log: "" +
+					"level='error'" +
+					" error='required flag --user was not set'" +
+					" flag='user'" +
+					" msg='required flag not set'\n" +
+					"level='error'" +
+					" error='required flag --count was not set: \"x\" is not an integer value'" +
+					" flag='count'" +
+					" msg='required flag not set'\n",
+			},
+		},
+		"flag value errors": {
+			eSlice: []error{
+				&cmdtoolkit.FlagValueError{Flag: "template", Value: "@tmpl", Err: errors.New("file does not exist")},
+			},
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "The value \"@tmpl\" for flag --template cannot be used: file does not exist.\n",
+				Log: "" +
+					"level='error'" +
+					" error='file does not exist'" +
+					" flag='template'" +
+					" value='@tmpl'" +
+					" msg='invalid flag value'\n",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -468,12 +659,62 @@ func TestProcessFlagErrors(t *testing.T) {
 	}
 }
 
+func TestExitErrorForFlagErrors(t *testing.T) {
+	tests := map[string]struct {
+		eSlice []error
+		want   *cmdtoolkit.ExitError
+	}{
+		"no errors": {
+			eSlice: nil,
+			want:   nil,
+		},
+		"only user errors": {
+			eSlice: []error{
+				&cmdtoolkit.RequiredFlagError{Flag: "f1"},
+				fmt.Errorf("wrapped: %w", &cmdtoolkit.RequiredFlagError{Flag: "f2"}),
+				&cmdtoolkit.FlagValueError{Flag: "f3", Value: "@", Err: errors.New("no file name follows '@'")},
+			},
+			want: cmdtoolkit.NewExitUserError("cmd"),
+		},
+		"mixed errors": {
+			eSlice: []error{
+				&cmdtoolkit.RequiredFlagError{Flag: "f1"},
+				errors.New("some error"),
+			},
+			want: cmdtoolkit.NewExitProgrammingError("cmd"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmdtoolkit.ExitErrorForFlagErrors("cmd", tt.eSlice); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExitErrorForFlagErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagValueError_Error(t *testing.T) {
+	e := &cmdtoolkit.FlagValueError{Flag: "template", Value: "@tmpl", Err: errors.New("file does not exist")}
+	if got, want := e.Error(), `invalid value "@tmpl" for flag --template: file does not exist`; got != want {
+		t.Errorf("FlagValueError.Error() = %q, want %q", got, want)
+	}
+}
+
+func TestFlagValueError_Unwrap(t *testing.T) {
+	cause := errors.New("file does not exist")
+	e := &cmdtoolkit.FlagValueError{Flag: "template", Value: "@tmpl", Err: cause}
+	if got := e.Unwrap(); got != cause {
+		t.Errorf("FlagValueError.Unwrap() = %v, want %v", got, cause)
+	}
+}
+
 type testFlagProducer struct{}
 
 func (testFlagProducer) Changed(_ string) bool              { return true }
 func (testFlagProducer) GetBool(_ string) (bool, error)     { return true, nil }
 func (testFlagProducer) GetInt(_ string) (int, error)       { return 12, nil }
 func (testFlagProducer) GetString(_ string) (string, error) { return "foo", nil }
+func (testFlagProducer) GetCount(_ string) (int, error)     { return 3, nil }
 
 func TestReadFlags(t *testing.T) {
 	type args struct {
@@ -515,13 +756,28 @@ func TestReadFlags(t *testing.T) {
 							Usage:           "",
 							DefaultValue:    []byte("blah"),
 						},
+						"r": {
+							AbbreviatedName: "",
+							Usage:           "",
+							ExpectedType:    cmdtoolkit.StringType,
+							DefaultValue:    "",
+							Required:        true,
+						},
+						"c": {
+							AbbreviatedName: "",
+							Usage:           "",
+							ExpectedType:    cmdtoolkit.CountType,
+							DefaultValue:    0,
+						},
 					},
 				},
 			},
 			want: map[string]*cmdtoolkit.CommandFlag[any]{
-				"b": {Value: true, UserSet: true},
-				"i": {Value: 12, UserSet: true},
-				"s": {Value: "foo", UserSet: true},
+				"b": {Value: true, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"i": {Value: 12, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"c": {Value: 3, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"r": {Value: "foo", UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"s": {Value: "foo", UserSet: true, Source: cmdtoolkit.CommandLineSource},
 			},
 			want1: 2,
 		},
@@ -544,3 +800,114 @@ func TestReadFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestReadFlags_commandLine(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "mySet",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"color": {
+				Usage:        "colorize output",
+				ExpectedType: cmdtoolkit.BoolType,
+				DefaultValue: false,
+				Negatable:    true,
+			},
+			"verbose": {
+				AbbreviatedName: "v",
+				Usage:           "increase verbosity",
+				ExpectedType:    cmdtoolkit.CountType,
+				DefaultValue:    0,
+			},
+		},
+	}
+	configured := &cmdtoolkit.Configuration{
+		ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+			"mySet": {
+				BoolMap: map[string]bool{"color": true},
+				IntMap:  map[string]int{"verbose": 1},
+			},
+		},
+	}
+	tests := map[string]struct {
+		c    *cmdtoolkit.Configuration
+		args []string
+		want map[string]*cmdtoolkit.CommandFlag[any]
+	}{
+		"defaults": {
+			c:    cmdtoolkit.EmptyConfiguration(),
+			args: []string{},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: false},
+				"verbose": {Value: 0},
+			},
+		},
+		"configured defaults": {
+			c:    configured,
+			args: []string{},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: true, Source: cmdtoolkit.ConfigurationSource},
+				"verbose": {Value: 1, Source: cmdtoolkit.ConfigurationSource},
+			},
+		},
+		"set on command line": {
+			c:    cmdtoolkit.EmptyConfiguration(),
+			args: []string{"--color", "-vvv", "-v"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: true, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"verbose": {Value: 4, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"configured defaults overridden": {
+			c:    configured,
+			args: []string{"--no-color", "--verbose", "-v"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: false, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"verbose": {Value: 2, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"configured count replaced": {
+			c:    configured,
+			args: []string{"-v"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: true, Source: cmdtoolkit.ConfigurationSource},
+				"verbose": {Value: 1, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"count set explicitly, then counted": {
+			c:    configured,
+			args: []string{"--verbose=5", "-v"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: true, Source: cmdtoolkit.ConfigurationSource},
+				"verbose": {Value: 6, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"negation negated": {
+			c:    configured,
+			args: []string{"--no-color=false"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"color":   {Value: true, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"verbose": {Value: 1, Source: cmdtoolkit.ConfigurationSource},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, tt.c, flags, set)
+			if parseErr := flags.Parse(tt.args); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			got, gotErrs := cmdtoolkit.ReadFlags(flags, set)
+			if len(gotErrs) != 0 {
+				t.Errorf("ReadFlags() errors = %v", gotErrs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFlags() got = %v, want %v", got, tt.want)
+			}
+			if negation := flags.Lookup("no-color"); negation == nil || !negation.Hidden {
+				t.Errorf("AddFlags() did not register --no-color as a hidden flag")
+			}
+			o.Report(t, "ReadFlags()", output.WantedRecording{})
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+// the code in this file tracks where each flag's value comes from: in increasing order of
+// precedence, the flag's built-in default, the configuration file, the flag's environment
+// variable, and the command line
+
+const flagSourceAnnotation = "cmd_toolkit_flag_source"
+
+// FlagSource identifies where a flag's value came from
+type FlagSource int32
+
+const (
+	// DefaultSource means that the value is the flag's built-in default value
+	DefaultSource FlagSource = iota
+	// ConfigurationSource means that the value came from the configuration file
+	ConfigurationSource
+	// EnvironmentSource means that the value came from the flag's environment variable
+	EnvironmentSource
+	// CommandLineSource means that the user set the value on the command line, or entered it
+	// when prompted for it
+	CommandLineSource
+)
+
+var flagSourceNames = map[FlagSource]string{
+	DefaultSource:       "default",
+	ConfigurationSource: "configuration",
+	EnvironmentSource:   "environment",
+	CommandLineSource:   "command line",
+}
+
+// String returns a description of the source, suitable for messages and logs
+func (fs FlagSource) String() string {
+	if name, ok := flagSourceNames[fs]; ok {
+		return name
+	}
+	return fmt.Sprintf("FlagSource(%d)", int32(fs))
+}
+
+// flagLookup is implemented by FlagProducers, such as *pflag.FlagSet, that can supply the
+// flag itself, and, with it, the annotation recording the source of its default value
+type flagLookup interface {
+	Lookup(name string) *pflag.Flag
+}
+
+// configured determines whether the configuration sets the flag's default value, either
+// under the flag's name or under one of its aliases
+func (fD *FlagDetails) configured(c configSource, name string) bool {
+	if c.hasKey(name) {
+		return true
+	}
+	for _, alias := range fD.Aliases {
+		if c.hasKey(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordSource applies the flag's environment variable, if it is set, to the flag's default
+// value, and records on the flag where that default value came from
+func (fD *FlagDetails) recordSource(o output.Bus, c configSource, consumer *pflag.FlagSet, flag flagParam) {
+	added := consumer.Lookup(flag.name)
+	if added == nil {
+		return
+	}
+	source := DefaultSource
+	if fD.configured(c, flag.name) {
+		source = ConfigurationSource
+	}
+	if fD.applyEnvironment(o, added, flag) {
+		source = EnvironmentSource
+	}
+	_ = consumer.SetAnnotation(flag.name, flagSourceAnnotation, []string{strconv.Itoa(int(source))})
+}
+
+// applyEnvironment sets the flag's default value from its environment variable; it returns
+// false if the variable is not set, or if its value cannot be used
+func (fD *FlagDetails) applyEnvironment(o output.Bus, added *pflag.Flag, flag flagParam) bool {
+	if fD.EnvVar == "" {
+		return false
+	}
+	value, defined := os.LookupEnv(fD.EnvVar)
+	if !defined {
+		return false
+	}
+	original := added.Value.String()
+	if setErr := added.Value.Set(value); setErr != nil {
+		// pflag's numeric values are overwritten even when parsing fails
+		_ = added.Value.Set(original)
+		reportInvalidEnvironmentValue(o, flag, fD.EnvVar, fD.shownValue(value), setErr)
+		return false
+	}
+	var bounds *IntBounds
+	switch fD.ExpectedType {
+	case IntType:
+		bounds, _ = fD.DefaultValue.(*IntBounds)
+	case CountType:
+		bounds = &IntBounds{MinValue: 0, MaxValue: math.MaxInt}
+	}
+	if bounds != nil {
+		n, _ := strconv.Atoi(added.Value.String())
+		_ = added.Value.Set(strconv.Itoa(bounds.ConstrainedValue(n)))
+	}
+	if !fD.validatedWhenRead() {
+		if invalid := fD.validate(flag.name, fD.typedValue(added.Value.String())); invalid != nil {
+			_ = added.Value.Set(original)
+			reportFlagValueError(o, invalid)
+			return false
+		}
+	}
+	added.DefValue = added.Value.String()
+	return true
+}
+
+// defaultSource returns the recorded source of the flag's default value
+func defaultSource(producer FlagProducer, name string) FlagSource {
+	lookup, ok := producer.(flagLookup)
+	if !ok {
+		return DefaultSource
+	}
+	f := lookup.Lookup(name)
+	if f == nil || len(f.Annotations[flagSourceAnnotation]) == 0 {
+		return DefaultSource
+	}
+	source, _ := strconv.Atoi(f.Annotations[flagSourceAnnotation][0])
+	return FlagSource(source)
+}
+
+// GetFlag gets the value of a specific flag, handling common error conditions; V must be the
+// type of value that ReadFlags produces for the flag: bool for BoolType flags, int for IntType
+// and CountType flags, and string for StringType and PathType flags
+func GetFlag[V commandFlagValue](
+	o output.Bus,
+	results map[string]*CommandFlag[any],
+	flagName string,
+) (CommandFlag[V], error) {
+	fv, flagNotFound := extractFlagValue(o, results, flagName)
+	if flagNotFound != nil {
+		return CommandFlag[V]{}, flagNotFound
+	}
+	if fv == nil {
+		return CommandFlag[V]{}, reportMissingFlagData(o, flagName)
+	}
+	v, ok := fv.Value.(V)
+	if !ok {
+		return CommandFlag[V]{}, reportIncorrectlyTypedValue(o, describeValueType[V](), flagName, fv)
+	}
+	return CommandFlag[V]{Value: v, UserSet: fv.UserSet, Source: fv.Source}, nil
+}
+
+func describeValueType[V any]() string {
+	var zero V
+	switch any(zero).(type) {
+	case bool:
+		return "a boolean"
+	case int:
+		return "an integer"
+	case string:
+		return "a string"
+	default:
+		return fmt.Sprintf("a value of type %s", reflect.TypeFor[V]())
+	}
+}
+
+func reportInvalidEnvironmentValue(o output.Bus, flag flagParam, envVar, value string, e error) {
+	o.ErrorPrintf(
+		"The value %q of environment variable %q cannot be used for flag --%s: %s.\n",
+		value,
+		envVar,
+		flag.name,
+		ErrorToString(e),
+	)
+	o.Log(output.Error, "invalid environment variable value", map[string]any{
+		"set":   flag.set,
+		"flag":  flag.name,
+		"name":  envVar,
+		"value": value,
+		"error": e,
+	})
+}
@@ -0,0 +1,239 @@
+package cmd_toolkit_test
+
+import (
+	"reflect"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+func TestFlagSource_String(t *testing.T) {
+	tests := map[string]struct {
+		fs   cmdtoolkit.FlagSource
+		want string
+	}{
+		"default":       {fs: cmdtoolkit.DefaultSource, want: "default"},
+		"configuration": {fs: cmdtoolkit.ConfigurationSource, want: "configuration"},
+		"environment":   {fs: cmdtoolkit.EnvironmentSource, want: "environment"},
+		"command line":  {fs: cmdtoolkit.CommandLineSource, want: "command line"},
+		"unknown":       {fs: cmdtoolkit.FlagSource(42), want: "FlagSource(42)"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.fs.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFlag(t *testing.T) {
+	results := map[string]*cmdtoolkit.CommandFlag[any]{
+		"verbose": {Value: 2, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+		"music":   {Value: "/music", Source: cmdtoolkit.EnvironmentSource},
+		"missing": nil,
+	}
+	t.Run("count", func(t *testing.T) {
+		o := output.NewRecorder()
+		got, err := cmdtoolkit.GetFlag[int](o, results, "verbose")
+		want := cmdtoolkit.CommandFlag[int]{Value: 2, UserSet: true, Source: cmdtoolkit.CommandLineSource}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("GetFlag() = %v, %v, want %v, nil", got, err, want)
+		}
+		o.Report(t, "GetFlag()", output.WantedRecording{})
+	})
+	t.Run("path", func(t *testing.T) {
+		o := output.NewRecorder()
+		got, err := cmdtoolkit.GetFlag[string](o, results, "music")
+		want := cmdtoolkit.CommandFlag[string]{Value: "/music", Source: cmdtoolkit.EnvironmentSource}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("GetFlag() = %v, %v, want %v, nil", got, err, want)
+		}
+		o.Report(t, "GetFlag()", output.WantedRecording{})
+	})
+	t.Run("mistyped", func(t *testing.T) {
+		o := output.NewRecorder()
+		if _, err := cmdtoolkit.GetFlag[float64](o, results, "verbose"); err == nil {
+			t.Errorf("GetFlag() error = nil")
+		}
+		o.Report(t, "GetFlag()", output.WantedRecording{
+			Error: "An internal error occurred: flag \"verbose\" is not a value of type float64 (2).\n",
+			Log: "level='error'" +
+				" error='flag value is not a value of type float64'" +
+				" flag='verbose'" +
+				" value='2'" +
+				" msg='internal error'\n",
+		})
+	})
+	t.Run("no data", func(t *testing.T) {
+		o := output.NewRecorder()
+		if _, err := cmdtoolkit.GetFlag[bool](o, results, "missing"); err == nil {
+			t.Errorf("GetFlag() error = nil")
+		}
+		o.Report(t, "GetFlag()", output.WantedRecording{
+			Error: "An internal error occurred: flag \"missing\" has no data.\n",
+			Log: "level='error'" +
+				" error='no data associated with flag'" +
+				" flag='missing'" +
+				" msg='internal error'\n",
+		})
+	})
+}
+
+func TestReadFlags_sources(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "list",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"format": {
+				Usage:        "output format",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "plain",
+				EnvVar:       "CMD_TOOLKIT_TEST_FORMAT",
+			},
+			"limit": {
+				Usage:        "maximum entries",
+				ExpectedType: cmdtoolkit.IntType,
+				DefaultValue: cmdtoolkit.NewIntBounds(1, 10, 100),
+				EnvVar:       "CMD_TOOLKIT_TEST_LIMIT",
+			},
+			"verbose": {
+				Usage:        "increase verbosity",
+				ExpectedType: cmdtoolkit.CountType,
+				DefaultValue: 0,
+				EnvVar:       "CMD_TOOLKIT_TEST_VERBOSE",
+			},
+			"token": {
+				Usage:        "access token",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Required:     true,
+				EnvVar:       "CMD_TOOLKIT_TEST_TOKEN",
+			},
+		},
+	}
+	configured := &cmdtoolkit.Configuration{
+		ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+			"list": {
+				StringMap: map[string]string{"format": "json"},
+				IntMap:    map[string]int{"limit": 20},
+			},
+		},
+	}
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+		want map[string]*cmdtoolkit.CommandFlag[any]
+		output.WantedRecording
+	}{
+		"configuration and environment": {
+			env: map[string]string{
+				"CMD_TOOLKIT_TEST_LIMIT":   "500",
+				"CMD_TOOLKIT_TEST_VERBOSE": "-2",
+				"CMD_TOOLKIT_TEST_TOKEN":   "secret",
+			},
+			args: []string{},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"format":  {Value: "json", Source: cmdtoolkit.ConfigurationSource},
+				"limit":   {Value: 100, Source: cmdtoolkit.EnvironmentSource},
+				"verbose": {Value: 0, Source: cmdtoolkit.EnvironmentSource},
+				"token":   {Value: "secret", Source: cmdtoolkit.EnvironmentSource},
+			},
+		},
+		"command line wins": {
+			env: map[string]string{
+				"CMD_TOOLKIT_TEST_FORMAT":  "csv",
+				"CMD_TOOLKIT_TEST_VERBOSE": "1",
+			},
+			args: []string{"--format", "xml", "--token", "t", "--verbose"},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"format":  {Value: "xml", UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"limit":   {Value: 20, Source: cmdtoolkit.ConfigurationSource},
+				"verbose": {Value: 1, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"token":   {Value: "t", UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"unusable environment value": {
+			env: map[string]string{
+				"CMD_TOOLKIT_TEST_LIMIT": "lots",
+				"CMD_TOOLKIT_TEST_TOKEN": "secret",
+			},
+			args: []string{},
+			want: map[string]*cmdtoolkit.CommandFlag[any]{
+				"format":  {Value: "json", Source: cmdtoolkit.ConfigurationSource},
+				"limit":   {Value: 20, Source: cmdtoolkit.ConfigurationSource},
+				"verbose": {Value: 0},
+				"token":   {Value: "secret", Source: cmdtoolkit.EnvironmentSource},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "The value \"lots\" of environment variable \"CMD_TOOLKIT_TEST_LIMIT\" cannot be used" +
+					" for flag --limit: '*strconv.NumError: strconv.ParseInt: parsing \"lots\": invalid syntax'.\n",
+				Log: "level='error'" +
+					" error='strconv.ParseInt: parsing \"lots\": invalid syntax'" +
+					" flag='limit'" +
+					" name='CMD_TOOLKIT_TEST_LIMIT'" +
+					" set='list'" +
+					" value='lots'" +
+					" msg='invalid environment variable value'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, configured, flags, set)
+			if parseErr := flags.Parse(tt.args); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			got, gotErrs := cmdtoolkit.ReadFlags(flags, set)
+			if len(gotErrs) != 0 {
+				t.Errorf("ReadFlags() errors = %v", gotErrs)
+			}
+			for k, v := range tt.want {
+				if !reflect.DeepEqual(got[k], v) {
+					t.Errorf("ReadFlags() got[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+			o.Report(t, "ReadFlags()", tt.WantedRecording)
+		})
+	}
+}
+
+func TestReadFlags_configuredRequiredFlag(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "login",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"name": {
+				Usage:        "account name",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Required:     true,
+			},
+		},
+	}
+	configured := &cmdtoolkit.Configuration{
+		ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+			"login": {StringMap: map[string]string{"name": "jane"}},
+		},
+	}
+	o := output.NewRecorder()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cmdtoolkit.AddFlags(o, configured, flags, set)
+	if parseErr := flags.Parse([]string{}); parseErr != nil {
+		t.Fatalf("Parse() failed: %v", parseErr)
+	}
+	got, gotErrs := cmdtoolkit.ReadFlags(flags, set)
+	if len(gotErrs) != 0 {
+		t.Errorf("ReadFlags() errors = %v", gotErrs)
+	}
+	want := &cmdtoolkit.CommandFlag[any]{Value: "jane", Source: cmdtoolkit.ConfigurationSource}
+	if !reflect.DeepEqual(got["name"], want) {
+		t.Errorf("ReadFlags() got %v, want %v", got["name"], want)
+	}
+	o.Report(t, "ReadFlags()", output.WantedRecording{})
+}
@@ -0,0 +1,240 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file derives a FlagSet from a struct type whose fields are annotated with
+// struct tags, and binds the values read by ReadFlags back into an instance of that struct.
+// These tags are recognized:
+//
+//   - flag: the flag's name; fields without this tag, or with the value "-", are ignored
+//   - abbr: the flag's single character abbreviation, if any
+//   - usage: a brief description of what the flag controls
+//   - default: the flag's default value
+//   - bounds: the minimum and maximum values of an integer flag, separated by a comma, e.g.,
+//     "1,10"; if omitted, the flag is unbounded. An omitted default is constrained to lie
+//     within the bounds
+//
+// A field's type determines the flag's type: bool, int, and string fields, including fields
+// of named types based on them, and CommandFlag[bool], CommandFlag[int], and
+// CommandFlag[string] fields, which also receive whether the user set the flag, are
+// supported. For example:
+//
+//	type listOptions struct {
+//		Format  string            `flag:"format" abbr:"f" usage:"output format" default:"plain"`
+//		Limit   int               `flag:"limit" usage:"maximum entries" default:"10" bounds:"1,100"`
+//		Details CommandFlag[bool] `flag:"details" usage:"include details"`
+//	}
+
+const (
+	flagTag    = "flag"
+	abbrTag    = "abbr"
+	usageTag   = "usage"
+	defaultTag = "default"
+	boundsTag  = "bounds"
+)
+
+var (
+	boolCommandFlagType   = reflect.TypeFor[CommandFlag[bool]]()
+	intCommandFlagType    = reflect.TypeFor[CommandFlag[int]]()
+	stringCommandFlagType = reflect.TypeFor[CommandFlag[string]]()
+)
+
+// FlagSetFromStruct creates a FlagSet with the specified name from the tagged fields of the
+// struct type T
+func FlagSetFromStruct[T any](name string) (*FlagSet, error) {
+	structType := reflect.TypeFor[T]()
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type %s is not a struct", structType)
+	}
+	set := &FlagSet{
+		Name:    name,
+		Details: map[string]*FlagDetails{},
+	}
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		flagName, tagged := taggedFlagName(field)
+		if !tagged {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, fmt.Errorf("field %s of type %s is not exported", field.Name, structType)
+		}
+		if _, duplicate := set.Details[flagName]; duplicate {
+			return nil, fmt.Errorf("flag %q is defined by more than one field of type %s", flagName, structType)
+		}
+		details, detailsErr := detailsFromField(field)
+		if detailsErr != nil {
+			return nil, fmt.Errorf("field %s of type %s: %w", field.Name, structType, detailsErr)
+		}
+		set.Details[flagName] = details
+	}
+	return set, nil
+}
+
+// Bind sets the tagged fields of the struct referenced by target from the flag values read
+// by ReadFlags; problems are reported in the same manner as GetBool, GetInt, and GetString
+// report them
+func Bind[T any](o output.Bus, results map[string]*CommandFlag[any], target *T) error {
+	if target == nil {
+		return errors.New("nil target")
+	}
+	structValue := reflect.ValueOf(target).Elem()
+	structType := structValue.Type()
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("type %s is not a struct", structType)
+	}
+	var bindErrs []error
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		flagName, tagged := taggedFlagName(field)
+		if !tagged || !field.IsExported() {
+			continue
+		}
+		expected, wrapped, supported := flagFieldType(field.Type)
+		if !supported {
+			bindErrs = append(bindErrs, fmt.Errorf("field %s has unsupported type %s", field.Name, field.Type))
+			continue
+		}
+		fieldValue := structValue.Field(i)
+		var bindErr error
+		switch expected {
+		case BoolType:
+			value, flagErr := GetBool(o, results, flagName)
+			bindErr = bindField(fieldValue, wrapped, value, flagErr)
+		case IntType:
+			value, flagErr := GetInt(o, results, flagName)
+			bindErr = bindField(fieldValue, wrapped, value, flagErr)
+		default:
+			value, flagErr := GetString(o, results, flagName)
+			bindErr = bindField(fieldValue, wrapped, value, flagErr)
+		}
+		if bindErr != nil {
+			bindErrs = append(bindErrs, fmt.Errorf("flag %q: %w", flagName, bindErr))
+		}
+	}
+	return errors.Join(bindErrs...)
+}
+
+func bindField[V bool | int | string](field reflect.Value, wrapped bool, value CommandFlag[V], flagErr error) error {
+	if flagErr != nil {
+		return flagErr
+	}
+	switch wrapped {
+	case true:
+		field.Set(reflect.ValueOf(value))
+	case false:
+		// the conversion accommodates fields of named types, such as type Format string
+		field.Set(reflect.ValueOf(value.Value).Convert(field.Type()))
+	}
+	return nil
+}
+
+func taggedFlagName(field reflect.StructField) (string, bool) {
+	name := field.Tag.Get(flagTag)
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// flagFieldType determines the flag type corresponding to a field's type, and whether the
+// field is a CommandFlag
+func flagFieldType(t reflect.Type) (expected valueType, wrapped, supported bool) {
+	switch t {
+	case boolCommandFlagType:
+		return BoolType, true, true
+	case intCommandFlagType:
+		return IntType, true, true
+	case stringCommandFlagType:
+		return StringType, true, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return BoolType, false, true
+	case reflect.Int:
+		return IntType, false, true
+	case reflect.String:
+		return StringType, false, true
+	}
+	return unspecifiedType, false, false
+}
+
+func detailsFromField(field reflect.StructField) (*FlagDetails, error) {
+	expected, _, supported := flagFieldType(field.Type)
+	if !supported {
+		return nil, fmt.Errorf("unsupported type %s", field.Type)
+	}
+	details := &FlagDetails{
+		AbbreviatedName: field.Tag.Get(abbrTag),
+		Usage:           field.Tag.Get(usageTag),
+		ExpectedType:    expected,
+	}
+	rawDefault := field.Tag.Get(defaultTag)
+	rawBounds, hasBounds := field.Tag.Lookup(boundsTag)
+	if hasBounds && expected != IntType {
+		return nil, errors.New("bounds are only supported for integer flags")
+	}
+	switch expected {
+	case BoolType:
+		details.DefaultValue = false
+		if rawDefault != "" {
+			value, parseErr := strconv.ParseBool(rawDefault)
+			if parseErr != nil {
+				return nil, fmt.Errorf("invalid boolean default %q", rawDefault)
+			}
+			details.DefaultValue = value
+		}
+	case IntType:
+		bounds, boundsErr := boundsFromTags(rawDefault, rawBounds, hasBounds)
+		if boundsErr != nil {
+			return nil, boundsErr
+		}
+		details.DefaultValue = bounds
+	default:
+		details.DefaultValue = rawDefault
+	}
+	return details, nil
+}
+
+func boundsFromTags(rawDefault, rawBounds string, hasBounds bool) (*IntBounds, error) {
+	bounds := &IntBounds{
+		MinValue: math.MinInt,
+		MaxValue: math.MaxInt,
+	}
+	if rawDefault != "" {
+		value, parseErr := strconv.Atoi(rawDefault)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid integer default %q", rawDefault)
+		}
+		bounds.DefaultValue = value
+	}
+	if hasBounds {
+		limits := strings.Split(rawBounds, ",")
+		if len(limits) != 2 {
+			return nil, fmt.Errorf("invalid bounds %q", rawBounds)
+		}
+		minValue, minErr := strconv.Atoi(strings.TrimSpace(limits[0]))
+		maxValue, maxErr := strconv.Atoi(strings.TrimSpace(limits[1]))
+		if minErr != nil || maxErr != nil || minValue > maxValue {
+			return nil, fmt.Errorf("invalid bounds %q", rawBounds)
+		}
+		bounds.MinValue = minValue
+		bounds.MaxValue = maxValue
+		if rawDefault == "" {
+			bounds.DefaultValue = bounds.ConstrainedValue(bounds.DefaultValue)
+		}
+	}
+	if bounds.DefaultValue < bounds.MinValue || bounds.DefaultValue > bounds.MaxValue {
+		return nil, fmt.Errorf("default %d is not within bounds %q", bounds.DefaultValue, rawBounds)
+	}
+	return bounds, nil
+}
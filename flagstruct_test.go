@@ -0,0 +1,255 @@
+package cmd_toolkit_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+type structuredOptions struct {
+	Format  string                       `flag:"format" abbr:"f" usage:"output format" default:"plain"`
+	Limit   int                          `flag:"limit" usage:"maximum entries" default:"10" bounds:"1,100"`
+	Offset  int                          `flag:"offset" usage:"entries to skip"`
+	Details cmdtoolkit.CommandFlag[bool] `flag:"details" usage:"include details" default:"true"`
+	Quiet   bool                         `flag:"quiet" abbr:"q"`
+	Ignored string
+	Skipped string `flag:"-"`
+}
+
+func TestFlagSetFromStruct(t *testing.T) {
+	type notAStruct int
+	type unsupportedType struct {
+		Ratio float64 `flag:"ratio"`
+	}
+	type unexportedField struct {
+		hidden string `flag:"hidden"`
+	}
+	type duplicateName struct {
+		A string `flag:"name"`
+		B string `flag:"name"`
+	}
+	type badBoolDefault struct {
+		B bool `flag:"b" default:"maybe"`
+	}
+	type badIntDefault struct {
+		I int `flag:"i" default:"many"`
+	}
+	type badBounds struct {
+		I int `flag:"i" bounds:"10"`
+	}
+	type inverseBounds struct {
+		I int `flag:"i" bounds:"10,1"`
+	}
+	type defaultOutOfBounds struct {
+		I int `flag:"i" default:"11" bounds:"1,10"`
+	}
+	type misplacedBounds struct {
+		S string `flag:"s" bounds:"1,10"`
+	}
+	tests := map[string]struct {
+		build   func(string) (*cmdtoolkit.FlagSet, error)
+		want    *cmdtoolkit.FlagSet
+		wantErr string
+	}{
+		"thorough": {
+			build: cmdtoolkit.FlagSetFromStruct[structuredOptions],
+			want: &cmdtoolkit.FlagSet{
+				Name: "mySet",
+				Details: map[string]*cmdtoolkit.FlagDetails{
+					"format": {
+						AbbreviatedName: "f",
+						Usage:           "output format",
+						ExpectedType:    cmdtoolkit.StringType,
+						DefaultValue:    "plain",
+					},
+					"limit": {
+						Usage:        "maximum entries",
+						ExpectedType: cmdtoolkit.IntType,
+						DefaultValue: cmdtoolkit.NewIntBounds(1, 10, 100),
+					},
+					"offset": {
+						Usage:        "entries to skip",
+						ExpectedType: cmdtoolkit.IntType,
+						DefaultValue: &cmdtoolkit.IntBounds{MinValue: math.MinInt, MaxValue: math.MaxInt},
+					},
+					"details": {
+						Usage:        "include details",
+						ExpectedType: cmdtoolkit.BoolType,
+						DefaultValue: true,
+					},
+					"quiet": {
+						AbbreviatedName: "q",
+						ExpectedType:    cmdtoolkit.BoolType,
+						DefaultValue:    false,
+					},
+				},
+			},
+		},
+		"not a struct": {
+			build:   cmdtoolkit.FlagSetFromStruct[notAStruct],
+			wantErr: "type cmd_toolkit_test.notAStruct is not a struct",
+		},
+		"unsupported type": {
+			build:   cmdtoolkit.FlagSetFromStruct[unsupportedType],
+			wantErr: "field Ratio of type cmd_toolkit_test.unsupportedType: unsupported type float64",
+		},
+		"unexported field": {
+			build:   cmdtoolkit.FlagSetFromStruct[unexportedField],
+			wantErr: "field hidden of type cmd_toolkit_test.unexportedField is not exported",
+		},
+		"duplicate name": {
+			build:   cmdtoolkit.FlagSetFromStruct[duplicateName],
+			wantErr: "flag \"name\" is defined by more than one field of type cmd_toolkit_test.duplicateName",
+		},
+		"bad bool default": {
+			build:   cmdtoolkit.FlagSetFromStruct[badBoolDefault],
+			wantErr: "field B of type cmd_toolkit_test.badBoolDefault: invalid boolean default \"maybe\"",
+		},
+		"bad int default": {
+			build:   cmdtoolkit.FlagSetFromStruct[badIntDefault],
+			wantErr: "field I of type cmd_toolkit_test.badIntDefault: invalid integer default \"many\"",
+		},
+		"bad bounds": {
+			build:   cmdtoolkit.FlagSetFromStruct[badBounds],
+			wantErr: "field I of type cmd_toolkit_test.badBounds: invalid bounds \"10\"",
+		},
+		"inverse bounds": {
+			build:   cmdtoolkit.FlagSetFromStruct[inverseBounds],
+			wantErr: "field I of type cmd_toolkit_test.inverseBounds: invalid bounds \"10,1\"",
+		},
+		"default out of bounds": {
+			build:   cmdtoolkit.FlagSetFromStruct[defaultOutOfBounds],
+			wantErr: "field I of type cmd_toolkit_test.defaultOutOfBounds: default 11 is not within bounds \"1,10\"",
+		},
+		"misplaced bounds": {
+			build: cmdtoolkit.FlagSetFromStruct[misplacedBounds],
+			wantErr: "field S of type cmd_toolkit_test.misplacedBounds: " +
+				"bounds are only supported for integer flags",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := tt.build("mySet")
+			if gotErr != nil {
+				if gotErr.Error() != tt.wantErr {
+					t.Errorf("FlagSetFromStruct() error = %q, want %q", gotErr, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("FlagSetFromStruct() error = nil, want %q", tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlagSetFromStruct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := map[string]struct {
+		results map[string]*cmdtoolkit.CommandFlag[any]
+		want    structuredOptions
+		wantErr bool
+		output.WantedRecording
+	}{
+		"happy": {
+			results: map[string]*cmdtoolkit.CommandFlag[any]{
+				"format":  {Value: "json", UserSet: true},
+				"limit":   {Value: 20},
+				"offset":  {Value: 5, UserSet: true},
+				"details": {Value: false, UserSet: true},
+				"quiet":   {Value: true},
+			},
+			want: structuredOptions{
+				Format:  "json",
+				Limit:   20,
+				Offset:  5,
+				Details: cmdtoolkit.CommandFlag[bool]{Value: false, UserSet: true},
+				Quiet:   true,
+			},
+		},
+		"missing and mistyped values": {
+			results: map[string]*cmdtoolkit.CommandFlag[any]{
+				"format":  {Value: "json", UserSet: true},
+				"limit":   {Value: "twenty"},
+				"details": {Value: false, UserSet: true},
+				"quiet":   {Value: true},
+			},
+			want: structuredOptions{
+				Format:  "json",
+				Details: cmdtoolkit.CommandFlag[bool]{Value: false, UserSet: true},
+				Quiet:   true,
+			},
+			wantErr: true,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An internal error occurred: flag \"limit\" is not an integer (twenty).\n" +
+					"An internal error occurred: flag \"offset\" is not found.\n",
+				Log: "" +
+					"level='error'" +
+					" error='flag value is not an integer'" +
+					" flag='limit'" +
+					" value='twenty'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='offset'" +
+					" msg='internal error'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			got := structuredOptions{}
+			if gotErr := cmdtoolkit.Bind(o, tt.results, &got); (gotErr != nil) != tt.wantErr {
+				t.Errorf("Bind() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Bind() got %v, want %v", got, tt.want)
+			}
+			o.Report(t, "Bind()", tt.WantedRecording)
+		})
+	}
+	t.Run("named types", func(t *testing.T) {
+		type format string
+		type level int
+		type toggle bool
+		type namedOptions struct {
+			Format format `flag:"format"`
+			Level  level  `flag:"level"`
+			Toggle toggle `flag:"toggle"`
+		}
+		if _, setErr := cmdtoolkit.FlagSetFromStruct[namedOptions]("named"); setErr != nil {
+			t.Errorf("FlagSetFromStruct() error = %v", setErr)
+		}
+		results := map[string]*cmdtoolkit.CommandFlag[any]{
+			"format": {Value: "json"},
+			"level":  {Value: 3},
+			"toggle": {Value: true},
+		}
+		got := namedOptions{}
+		if gotErr := cmdtoolkit.Bind(output.NewNilBus(), results, &got); gotErr != nil {
+			t.Errorf("Bind() error = %v", gotErr)
+		}
+		if want := (namedOptions{Format: "json", Level: 3, Toggle: true}); got != want {
+			t.Errorf("Bind() got %v, want %v", got, want)
+		}
+	})
+	t.Run("nil target", func(t *testing.T) {
+		if gotErr := cmdtoolkit.Bind[structuredOptions](output.NewNilBus(), nil, nil); gotErr == nil {
+			t.Errorf("Bind() error = nil, want error")
+		}
+	})
+	t.Run("not a struct", func(t *testing.T) {
+		target := 0
+		if gotErr := cmdtoolkit.Bind(output.NewNilBus(), nil, &target); gotErr == nil {
+			t.Errorf("Bind() error = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file validates flag values against rules supplied by the application; the
+// same rules, and the same messages, apply whether a value comes from the configuration file,
+// an environment variable, or the command line. Built-in default values are not checked, as
+// they are the application's responsibility
+
+// ValueFormat specifies a format that a string flag's value must satisfy
+type ValueFormat int32
+
+const (
+	// AnyFormat places no requirement on the value's format
+	AnyFormat ValueFormat = iota
+	// URLFormat requires the value to be an absolute URL, such as https://example.com/path
+	URLFormat
+	// DurationFormat requires the value to be a duration, such as 90s or 1h30m, as
+	// understood by time.ParseDuration
+	DurationFormat
+)
+
+// ValidationRules describes the rules that a flag's value must satisfy; the zero value
+// imposes no rules. Pattern, MinLength, MaxLength, and Format apply only to the values of
+// string and path flags; Custom applies to values of every type
+type ValidationRules struct {
+	// Pattern, if not nil, must match the value; anchor it (^...$) to match the entire value
+	Pattern *regexp.Regexp
+	// MinLength is the minimum length of the value, in characters
+	MinLength int
+	// MaxLength, if positive, is the maximum length of the value, in characters
+	MaxLength int
+	// Format is the format that the value must satisfy
+	Format ValueFormat
+	// Custom, if not nil, is called with the value (a bool, int, or string, depending on the
+	// flag's type), and returns an error describing why the value is unacceptable
+	Custom func(any) error
+}
+
+// check returns an error describing why the value breaks the rules, or nil
+func (vr ValidationRules) check(value any) error {
+	if s, isString := value.(string); isString {
+		if checkErr := vr.checkString(s); checkErr != nil {
+			return checkErr
+		}
+	}
+	if vr.Custom != nil {
+		return vr.Custom(value)
+	}
+	return nil
+}
+
+func (vr ValidationRules) checkString(s string) error {
+	length := utf8.RuneCountInString(s)
+	switch {
+	case length < vr.MinLength:
+		return fmt.Errorf("shorter than %d characters", vr.MinLength)
+	case vr.MaxLength > 0 && length > vr.MaxLength:
+		return fmt.Errorf("longer than %d characters", vr.MaxLength)
+	case vr.Pattern != nil && !vr.Pattern.MatchString(s):
+		return fmt.Errorf("does not match the pattern %q", vr.Pattern.String())
+	}
+	switch vr.Format {
+	case URLFormat:
+		u, parseErr := url.Parse(s)
+		if parseErr != nil {
+			return fmt.Errorf("not a URL: %w", errors.Unwrap(parseErr))
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("not an absolute URL")
+		}
+	case DurationFormat:
+		if _, parseErr := time.ParseDuration(s); parseErr != nil {
+			return errors.New("not a duration, such as 90s or 1h30m")
+		}
+	}
+	return nil
+}
+
+// validate checks a value against the flag's rules, returning a *FlagValueError if the
+// value breaks them
+func (fD *FlagDetails) validate(name string, value any) *FlagValueError {
+	if checkErr := fD.Rules.check(value); checkErr != nil {
+		return &FlagValueError{Flag: name, Value: fD.shownValue(value), Err: checkErr}
+	}
+	return nil
+}
+
+// maskedValue is shown in place of the value of a Secret flag
+const maskedValue = "********"
+
+// shownValue returns the value as it may appear in error messages and in the log
+func (fD *FlagDetails) shownValue(value any) string {
+	if fD.Secret {
+		return maskedValue
+	}
+	return fmt.Sprint(value)
+}
+
+// validatedWhenRead returns true if the flag's rules apply, not to the value supplied, but
+// to the value that ReadFlags derives from it: a path flag's expanded path, or the content
+// of the file named by a FileValue flag
+func (fD *FlagDetails) validatedWhenRead() bool {
+	return fD.ExpectedType == PathType || fD.FileValue
+}
+
+// acceptsConfiguredDefault validates the flag's default value if it came from the
+// configuration file, reporting the value if it breaks the rules. A value that
+// validatedWhenRead applies to is checked later, by ReadFlags
+func (fD *FlagDetails) acceptsConfiguredDefault(o output.Bus, c configSource, flag flagParam, value any) bool {
+	if fD.validatedWhenRead() || !fD.configured(c, flag.name) {
+		return true
+	}
+	if invalid := fD.validate(flag.name, value); invalid != nil {
+		reportFlagValueError(o, invalid)
+		return false
+	}
+	return true
+}
+
+// typedValue returns the flag's current value as the type that ReadFlags would produce
+func (fD *FlagDetails) typedValue(s string) any {
+	switch fD.ExpectedType {
+	case BoolType:
+		b, _ := strconv.ParseBool(s)
+		return b
+	case IntType, CountType:
+		i, _ := strconv.Atoi(s)
+		return i
+	default:
+		return s
+	}
+}
+
+func (vr ValidationRules) notes() []string {
+	var notes []string
+	switch {
+	case vr.MinLength > 0 && vr.MaxLength > 0:
+		notes = append(notes, fmt.Sprintf("%d to %d characters", vr.MinLength, vr.MaxLength))
+	case vr.MinLength > 0:
+		notes = append(notes, fmt.Sprintf("at least %d characters", vr.MinLength))
+	case vr.MaxLength > 0:
+		notes = append(notes, fmt.Sprintf("at most %d characters", vr.MaxLength))
+	}
+	if vr.Pattern != nil {
+		notes = append(notes, fmt.Sprintf("must match %s", vr.Pattern.String()))
+	}
+	switch vr.Format {
+	case URLFormat:
+		notes = append(notes, "must be an absolute URL")
+	case DurationFormat:
+		notes = append(notes, "must be a duration")
+	}
+	return notes
+}
@@ -0,0 +1,151 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestValidationRules_check(t *testing.T) {
+	evenOnly := func(v any) error {
+		if n, ok := v.(int); ok && n%2 != 0 {
+			return errors.New("not an even number")
+		}
+		return nil
+	}
+	tests := map[string]struct {
+		vr      ValidationRules
+		value   any
+		wantErr string
+	}{
+		"no rules":               {vr: ValidationRules{}, value: "anything"},
+		"too short":              {vr: ValidationRules{MinLength: 3}, value: "ab", wantErr: "shorter than 3 characters"},
+		"long enough":            {vr: ValidationRules{MinLength: 3}, value: "äöü"},
+		"too long":               {vr: ValidationRules{MaxLength: 3}, value: "abcd", wantErr: "longer than 3 characters"},
+		"short enough":           {vr: ValidationRules{MaxLength: 3}, value: "abc"},
+		"pattern matched":        {vr: ValidationRules{Pattern: regexp.MustCompile(`^[a-z]+$`)}, value: "abc"},
+		"pattern not matched":    {vr: ValidationRules{Pattern: regexp.MustCompile(`^[a-z]+$`)}, value: "ab1", wantErr: "does not match the pattern \"^[a-z]+$\""},
+		"url":                    {vr: ValidationRules{Format: URLFormat}, value: "https://example.com/path"},
+		"relative url":           {vr: ValidationRules{Format: URLFormat}, value: "/path", wantErr: "not an absolute URL"},
+		"malformed url":          {vr: ValidationRules{Format: URLFormat}, value: "http://[::1", wantErr: "not a URL: missing ']' in host"},
+		"duration":               {vr: ValidationRules{Format: DurationFormat}, value: "1h30m"},
+		"not a duration":         {vr: ValidationRules{Format: DurationFormat}, value: "soon", wantErr: "not a duration, such as 90s or 1h30m"},
+		"custom accepts":         {vr: ValidationRules{Custom: evenOnly}, value: 2},
+		"custom rejects":         {vr: ValidationRules{Custom: evenOnly}, value: 3, wantErr: "not an even number"},
+		"string rules ignored":   {vr: ValidationRules{MinLength: 3}, value: 1},
+		"string rules run first": {vr: ValidationRules{MinLength: 3, Custom: func(any) error { return errors.New("custom") }}, value: "ab", wantErr: "shorter than 3 characters"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.vr.check(tt.value)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("check() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlagDetails_validate(t *testing.T) {
+	tests := map[string]struct {
+		fD    *FlagDetails
+		value any
+		want  *FlagValueError
+	}{
+		"valid": {
+			fD:    &FlagDetails{Rules: ValidationRules{MinLength: 1}},
+			value: "x",
+			want:  nil,
+		},
+		"invalid": {
+			fD:    &FlagDetails{Rules: ValidationRules{MinLength: 2}},
+			value: "x",
+			want:  &FlagValueError{Flag: "name", Value: "x", Err: errors.New("shorter than 2 characters")},
+		},
+		"invalid secret": {
+			fD:    &FlagDetails{Secret: true, Rules: ValidationRules{MinLength: 8}},
+			value: "hunter2",
+			want:  &FlagValueError{Flag: "name", Value: "********", Err: errors.New("shorter than 8 characters")},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.fD.validate("name", tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagDetails_refineValue_fileValueNotShown(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	_ = afero.WriteFile(fileSystem, "tok", []byte("supersecrettoken\n"), StdFilePermissions)
+	tests := map[string]struct {
+		fD        *FlagDetails
+		wantValue string
+	}{
+		"file value": {
+			fD:        &FlagDetails{ExpectedType: StringType, FileValue: true, Rules: ValidationRules{MinLength: 40}},
+			wantValue: "@tok",
+		},
+		"secret file value": {
+			fD: &FlagDetails{
+				ExpectedType: StringType,
+				FileValue:    true,
+				Secret:       true,
+				Rules:        ValidationRules{MinLength: 40},
+			},
+			wantValue: maskedValue,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			val := &CommandFlag[any]{Value: "@tok", UserSet: true, Source: CommandLineSource}
+			refineErr := tt.fD.refineValue("token", val, &readState{})
+			var invalid *FlagValueError
+			if !errors.As(refineErr, &invalid) {
+				t.Fatalf("refineValue() error = %v, want a *FlagValueError", refineErr)
+			}
+			if invalid.Value != tt.wantValue {
+				t.Errorf("refineValue() reported value %q, want %q", invalid.Value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestValidationRules_notes(t *testing.T) {
+	tests := map[string]struct {
+		vr   ValidationRules
+		want []string
+	}{
+		"none":    {vr: ValidationRules{}, want: nil},
+		"minimum": {vr: ValidationRules{MinLength: 2}, want: []string{"at least 2 characters"}},
+		"maximum": {vr: ValidationRules{MaxLength: 9}, want: []string{"at most 9 characters"}},
+		"everything": {
+			vr: ValidationRules{
+				MinLength: 2,
+				MaxLength: 9,
+				Pattern:   regexp.MustCompile(`^\w+$`),
+				Format:    DurationFormat,
+			},
+			want: []string{"2 to 9 characters", `must match ^\w+$`, "must be a duration"},
+		},
+		"url": {vr: ValidationRules{Format: URLFormat}, want: []string{"must be an absolute URL"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.vr.notes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package cmd_toolkit_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+func TestReadFlags_validation(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "fetch",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"server": {
+				Usage:        "server URL",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				EnvVar:       "CMD_TOOLKIT_TEST_SERVER",
+				Rules:        cmdtoolkit.ValidationRules{Format: cmdtoolkit.URLFormat},
+			},
+			"timeout": {
+				Usage:        "how long to wait",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "30s",
+				Rules:        cmdtoolkit.ValidationRules{Format: cmdtoolkit.DurationFormat},
+			},
+			"tag": {
+				Usage:        "release tag",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				Rules: cmdtoolkit.ValidationRules{
+					Pattern:   regexp.MustCompile(`^v\d+$`),
+					MaxLength: 4,
+				},
+			},
+			"retries": {
+				Usage:        "retry count",
+				ExpectedType: cmdtoolkit.IntType,
+				DefaultValue: cmdtoolkit.NewIntBounds(0, 1, 10),
+				Rules: cmdtoolkit.ValidationRules{Custom: func(v any) error {
+					if v.(int) == 7 {
+						return errors.New("unlucky")
+					}
+					return nil
+				}},
+			},
+		},
+	}
+	tests := map[string]struct {
+		config     *cmdtoolkit.Configuration
+		env        map[string]string
+		args       []string
+		wantFlags  map[string]*cmdtoolkit.CommandFlag[any]
+		wantErrors []error
+		output.WantedRecording
+	}{
+		"valid values": {
+			config: &cmdtoolkit.Configuration{
+				ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+					"fetch": {StringMap: map[string]string{"timeout": "1m"}},
+				},
+			},
+			env:  map[string]string{"CMD_TOOLKIT_TEST_SERVER": "https://example.com"},
+			args: []string{"--tag", "v12", "--retries", "3"},
+			wantFlags: map[string]*cmdtoolkit.CommandFlag[any]{
+				"server":  {Value: "https://example.com", Source: cmdtoolkit.EnvironmentSource},
+				"timeout": {Value: "1m", Source: cmdtoolkit.ConfigurationSource},
+				"tag":     {Value: "v12", UserSet: true, Source: cmdtoolkit.CommandLineSource},
+				"retries": {Value: 3, UserSet: true, Source: cmdtoolkit.CommandLineSource},
+			},
+		},
+		"invalid command line values": {
+			config: cmdtoolkit.EmptyConfiguration(),
+			args:   []string{"--tag", "v12345", "--retries", "7", "--timeout", "later"},
+			wantFlags: map[string]*cmdtoolkit.CommandFlag[any]{
+				"server": {Value: ""},
+			},
+			wantErrors: []error{
+				&cmdtoolkit.FlagValueError{Flag: "retries", Value: "7", Err: errors.New("unlucky")},
+				&cmdtoolkit.FlagValueError{Flag: "tag", Value: "v12345", Err: errors.New("longer than 4 characters")},
+				&cmdtoolkit.FlagValueError{
+					Flag:  "timeout",
+					Value: "later",
+					Err:   errors.New("not a duration, such as 90s or 1h30m"),
+				},
+			},
+		},
+		"invalid configured and environment values": {
+			config: &cmdtoolkit.Configuration{
+				ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+					"fetch": {
+						StringMap: map[string]string{"timeout": "later", "server": "https://example.com"},
+						IntMap:    map[string]int{"retries": 7},
+					},
+				},
+			},
+			env:  map[string]string{"CMD_TOOLKIT_TEST_SERVER": "example.com"},
+			args: []string{},
+			wantFlags: map[string]*cmdtoolkit.CommandFlag[any]{
+				"server": {Value: "https://example.com", Source: cmdtoolkit.ConfigurationSource},
+				"tag":    {Value: ""},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The value \"7\" for flag --retries cannot be used: unlucky.\n" +
+					"The value \"example.com\" for flag --server cannot be used: not an absolute URL.\n" +
+					"The value \"later\" for flag --timeout cannot be used: not a duration, such as 90s or 1h30m.\n",
+				Log: "" +
+					"level='error' error='unlucky' flag='retries' value='7' msg='invalid flag value'\n" +
+					"level='error'" +
+					" error='not an absolute URL'" +
+					" flag='server'" +
+					" value='example.com'" +
+					" msg='invalid flag value'\n" +
+					"level='error'" +
+					" error='not a duration, such as 90s or 1h30m'" +
+					" flag='timeout'" +
+					" value='later'" +
+					" msg='invalid flag value'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("fetch", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, tt.config, flags, set)
+			if parseErr := flags.Parse(tt.args); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			got, gotErrs := cmdtoolkit.ReadFlags(flags, set)
+			for k, v := range tt.wantFlags {
+				if !reflect.DeepEqual(got[k], v) {
+					t.Errorf("ReadFlags() got[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+			if tt.wantErrors != nil && !reflect.DeepEqual(gotErrs, tt.wantErrors) {
+				t.Errorf("ReadFlags() errors = %v, want %v", gotErrs, tt.wantErrors)
+			}
+			o.Report(t, "ReadFlags()", tt.WantedRecording)
+		})
+	}
+}
+
+func TestReadFlags_fileValueValidation(t *testing.T) {
+	dir := t.TempDir()
+	lowerCase := filepath.Join(dir, "lower")
+	upperCase := filepath.Join(dir, "upper")
+	_ = os.WriteFile(lowerCase, []byte("token\n"), cmdtoolkit.StdFilePermissions)
+	_ = os.WriteFile(upperCase, []byte("TOKEN\n"), cmdtoolkit.StdFilePermissions)
+	set := &cmdtoolkit.FlagSet{
+		Name: "fetch",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			"token": {
+				Usage:        "access token",
+				ExpectedType: cmdtoolkit.StringType,
+				DefaultValue: "",
+				FileValue:    true,
+				EnvVar:       "CMD_TOOLKIT_TEST_TOKEN",
+				Rules:        cmdtoolkit.ValidationRules{Pattern: regexp.MustCompile(`^[a-z]+$`)},
+			},
+		},
+	}
+	configured := func(value string) *cmdtoolkit.Configuration {
+		return &cmdtoolkit.Configuration{
+			ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+				"fetch": {StringMap: map[string]string{"token": value}},
+			},
+		}
+	}
+	tests := map[string]struct {
+		config     *cmdtoolkit.Configuration
+		env        map[string]string
+		want       *cmdtoolkit.CommandFlag[any]
+		wantErrors []error
+	}{
+		"configured file": {
+			config: configured("@" + lowerCase),
+			want:   &cmdtoolkit.CommandFlag[any]{Value: "token", Source: cmdtoolkit.ConfigurationSource},
+		},
+		"environment file": {
+			config: cmdtoolkit.EmptyConfiguration(),
+			env:    map[string]string{"CMD_TOOLKIT_TEST_TOKEN": "@" + lowerCase},
+			want:   &cmdtoolkit.CommandFlag[any]{Value: "token", Source: cmdtoolkit.EnvironmentSource},
+		},
+		"configured file with invalid content": {
+			config: configured("@" + upperCase),
+			wantErrors: []error{
+				&cmdtoolkit.FlagValueError{
+					Flag:  "token",
+					Value: "@" + upperCase,
+					Err:   errors.New("does not match the pattern \"^[a-z]+$\""),
+				},
+			},
+		},
+		"environment file with invalid content": {
+			config: cmdtoolkit.EmptyConfiguration(),
+			env:    map[string]string{"CMD_TOOLKIT_TEST_TOKEN": "@" + upperCase},
+			wantErrors: []error{
+				&cmdtoolkit.FlagValueError{
+					Flag:  "token",
+					Value: "@" + upperCase,
+					Err:   errors.New("does not match the pattern \"^[a-z]+$\""),
+				},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("fetch", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, tt.config, flags, set)
+			if parseErr := flags.Parse(nil); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			got, gotErrs := cmdtoolkit.ReadFlags(flags, set)
+			if tt.want != nil && !reflect.DeepEqual(got["token"], tt.want) {
+				t.Errorf("ReadFlags() got[token] = %v, want %v", got["token"], tt.want)
+			}
+			if !reflect.DeepEqual(gotErrs, tt.wantErrors) {
+				t.Errorf("ReadFlags() errors = %v, want %v", gotErrs, tt.wantErrors)
+			}
+			o.Report(t, "AddFlags()", output.WantedRecording{})
+		})
+	}
+}
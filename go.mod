@@ -7,14 +7,17 @@ require (
 	github.com/majohn-r/output v0.10.2
 	github.com/mattn/go-isatty v0.0.20
 	github.com/spf13/afero v1.15.0
+	github.com/spf13/cobra v1.10.2
 	github.com/spf13/pflag v1.0.10
 	github.com/utahta/go-cronowriter v1.2.0
 	golang.org/x/sys v0.42.0
+	golang.org/x/term v0.41.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/lestrrat-go/strftime v1.1.1 // indirect
 	golang.org/x/text v0.35.0 // indirect
 )
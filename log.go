@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"regexp"
-	"sort"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/majohn-r/output"
@@ -19,7 +17,29 @@ type simpleLogger struct {
 	writer          io.Writer
 	exitFunction    exitFunc
 	currentLogLevel output.Level
+	format          LogFormat
+	retention       RetentionPolicy
+	redaction       RedactionRules
+	callerSkips     []string
+	async           asyncSettings
+	sinks           []registeredSink
+	lastSinkID      LogSinkID
+	sinkThreshold   atomic.Uint32
 	lock            *sync.RWMutex
+	// writeLock serializes writes to the log file and the sinks; records are formatted
+	// before it is acquired
+	writeLock sync.Mutex
+}
+
+// loggerState is a snapshot of the settings that govern how a record is logged, so that a
+// record can be formatted and written without holding the lock that guards those settings
+type loggerState struct {
+	writer      io.Writer
+	level       output.Level
+	format      LogFormat
+	redaction   RedactionRules
+	callerSkips []string
+	sinks       []registeredSink
 }
 
 const defaultLoggingLevel = output.Info
@@ -29,6 +49,8 @@ var (
 	ProductionLogger = &simpleLogger{
 		exitFunction:    os.Exit,
 		currentLogLevel: defaultLoggingLevel,
+		retention:       DefaultRetentionPolicy,
+		redaction:       DefaultRedactionRules,
 		lock:            &sync.RWMutex{},
 	}
 	logPath string
@@ -44,41 +66,62 @@ func LogPath() string {
 }
 
 // InitLogging sets up logging at the default log level
-func InitLogging(o output.Bus, applicationName string) (ok bool) {
-	return InitLoggingWithLevel(o, defaultLoggingLevel, applicationName)
+func InitLogging(o output.Bus, applicationName string, options ...LogOption) (ok bool) {
+	return InitLoggingWithLevel(o, defaultLoggingLevel, applicationName, options...)
 }
 
-// InitLoggingWithLevel initializes logging with a specific log level
-func InitLoggingWithLevel(o output.Bus, l output.Level, applicationName string) (ok bool) {
+// InitLoggingWithLevel initializes logging with a specific log level; options, such as
+// WithLogFormat, WithRetentionPolicy, WithRedaction, and WithAsyncWriter, customize the
+// logger further
+func InitLoggingWithLevel(o output.Bus, l output.Level, applicationName string, options ...LogOption) (ok bool) {
+	ProductionLogger.lock.Lock()
+	ProductionLogger.format = TextLogFormat
+	ProductionLogger.retention = DefaultRetentionPolicy
+	ProductionLogger.redaction = DefaultRedactionRules
+	ProductionLogger.callerSkips = nil
+	ProductionLogger.async = asyncSettings{}
+	ProductionLogger.removeSinks()
+	for _, option := range options {
+		option(ProductionLogger)
+	}
+	ProductionLogger.lock.Unlock()
 	if w, p := LogWriterInitFn(o, applicationName); w != nil {
 		logPath = p
+		ProductionLogger.lock.Lock()
+		if ProductionLogger.async.queueSize > 0 {
+			w = newAsyncWriter(w, ProductionLogger.async)
+		}
+		previous := ProductionLogger.writer
 		ProductionLogger.writer = w
 		ProductionLogger.currentLogLevel = l
+		ProductionLogger.lock.Unlock()
+		stopAsyncWriter(previous)
 		ok = true
 	}
 	return
 }
 
-// WillLog returns true if the implementation will log messages at a specified level
+// WillLog returns true if the implementation will log messages at a specified level,
+// either to the log file or to any of its sinks
 func (sl *simpleLogger) WillLog(l output.Level) bool {
-	return l <= sl.currentLogLevel
+	return l <= sl.Level() || sl.sinksWillLog(l)
 }
 
-var typicalChars = regexp.MustCompile(`^[a-zA-Z0-9._/@^+-]+$`)
-
+// requiresQuotes returns true unless s is a non-empty string consisting solely of letters,
+// digits, and the characters . _ / @ ^ + -
 func requiresQuotes(s string) bool {
-	return !typicalChars.MatchString(s)
-}
-
-func toString(v any) string {
-	value, ok := v.(string)
-	if !ok {
-		value = fmt.Sprint(v)
+	if s == "" {
+		return true
 	}
-	if requiresQuotes(value) {
-		return fmt.Sprintf("%q", value)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.', c == '_', c == '/', c == '@', c == '^', c == '+', c == '-':
+		default:
+			return true
+		}
 	}
-	return value
+	return false
 }
 
 var levelsToString = map[output.Level]string{
@@ -91,37 +134,82 @@ var levelsToString = map[output.Level]string{
 	output.Trace:   "trace",
 }
 
+// state returns a snapshot of the logger's settings; the sinks slice is never modified in
+// place, so it is safe to share
+func (sl *simpleLogger) state() loggerState {
+	sl.lock.RLock()
+	defer sl.lock.RUnlock()
+	return loggerState{
+		writer:      sl.writer,
+		level:       sl.currentLogLevel,
+		format:      sl.format,
+		redaction:   sl.redaction,
+		callerSkips: sl.callerSkips,
+		sinks:       sl.sinks,
+	}
+}
+
 func (sl *simpleLogger) log(l output.Level, msg string, fields map[string]any) {
-	if sl.WillLog(l) {
-		sl.doLog(l, time.Now(), msg, fields)
+	s := sl.state()
+	if l <= s.level || sl.sinksWillLog(l) {
+		if s.callerSkips != nil {
+			fields = addCaller(fields, s.callerSkips)
+		}
+		sl.emit(s, l, time.Now(), msg, fields)
 	}
 }
 
 func (sl *simpleLogger) doLog(l output.Level, timestamp time.Time, msg string, fields map[string]any) {
-	var fieldMap = map[string]string{}
-	fieldKeys := make([]string, len(fields))
-	if len(fields) > 0 {
-		index := 0
-		for k, v := range fields {
-			fieldKeys[index] = k
-			index++
-			fieldMap[k] = toString(v)
+	sl.emit(sl.state(), l, timestamp, msg, fields)
+}
+
+// emit redacts and formats a record outside any lock, and then writes it to each
+// destination that accepts its level, with a single write per destination
+func (sl *simpleLogger) emit(s loggerState, l output.Level, timestamp time.Time, msg string, fields map[string]any) {
+	msg, fields = s.redaction.redactRecord(msg, fields)
+	// each format is rendered at most once, however many destinations use it
+	var records [CompactLogFormat + 1]*recordBuffer
+	render := func(f LogFormat) []byte {
+		if f < TextLogFormat || f > CompactLogFormat {
+			f = TextLogFormat
+		}
+		if records[f] == nil {
+			records[f] = getRecordBuffer()
+			records[f].render(f, l, timestamp, msg, fields)
+		}
+		return records[f].b
+	}
+	toWriter := s.writer != nil && l <= s.level
+	if toWriter {
+		render(s.format)
+	}
+	for _, sink := range s.sinks {
+		if l <= sink.Level {
+			render(sink.Format)
 		}
-		sort.Strings(fieldKeys)
 	}
-	levelValue := levelsToString[l]
-	msgValue := toString(msg)
-	sl.lock.Lock()
-	defer sl.lock.Unlock()
-	tValue := timestamp.Format(time.RFC3339)
-	loggedFields := make([]string, 3+len(fieldKeys))
-	loggedFields[0] = fmt.Sprintf("time=%q", tValue)
-	loggedFields[1] = fmt.Sprintf("level=%s", levelValue)
-	loggedFields[2] = fmt.Sprintf("msg=%s", msgValue)
-	for index, k := range fieldKeys {
-		loggedFields[index+3] = fmt.Sprintf("%s=%s", k, fieldMap[k])
+	sl.writeLock.Lock()
+	if toWriter {
+		_, _ = s.writer.Write(render(s.format))
 	}
-	fmt.Fprintln(sl.writer, strings.Join(loggedFields, " "))
+	for _, sink := range s.sinks {
+		if l <= sink.Level {
+			_, _ = sink.Writer.Write(render(sink.Format))
+		}
+	}
+	sl.writeLock.Unlock()
+	for _, record := range records {
+		if record != nil {
+			record.release()
+		}
+	}
+}
+
+func formatTextRecord(l output.Level, timestamp time.Time, msg string, fields map[string]any) string {
+	rb := getRecordBuffer()
+	defer rb.release()
+	rb.appendTextRecord(l, timestamp, msg, fields)
+	return string(rb.b)
 }
 
 // Debug outputs a debug log message
@@ -134,9 +222,10 @@ func (sl *simpleLogger) Error(msg string, fields map[string]any) {
 	sl.log(output.Error, msg, fields)
 }
 
-// Fatal outputs a fatal log message and terminates the program
+// Fatal outputs a fatal log message, flushes the log, and terminates the program
 func (sl *simpleLogger) Fatal(msg string, fields map[string]any) {
 	sl.log(output.Fatal, msg, fields)
+	_ = sl.Flush()
 	sl.exitFunction(0)
 }
 
@@ -145,9 +234,10 @@ func (sl *simpleLogger) Info(msg string, fields map[string]any) {
 	sl.log(output.Info, msg, fields)
 }
 
-// Panic outputs a panic log message and calls panic()
+// Panic outputs a panic log message, flushes the log, and calls panic()
 func (sl *simpleLogger) Panic(msg string, fields map[string]any) {
 	sl.log(output.Panic, msg, fields)
+	_ = sl.Flush()
 	panic(msg)
 }
 
@@ -941,7 +941,7 @@ func Test_simpleLogger_willLog(t *testing.T) {
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			pl := simpleLogger{currentLogLevel: tt.cl}
+			pl := simpleLogger{currentLogLevel: tt.cl, lock: &sync.RWMutex{}}
 			if got := pl.WillLog(tt.l); got != tt.want {
 				t.Errorf("simpleLogger.WillLog() = %v, want %v", got, tt.want)
 			}
@@ -968,7 +968,7 @@ func Test_requiresQuotes(t *testing.T) {
 	}
 }
 
-func Test_toString(t *testing.T) {
+func Test_appendTextValue(t *testing.T) {
 	tests := map[string]struct {
 		v    any
 		want string
@@ -980,7 +980,7 @@ func Test_toString(t *testing.T) {
 		"plain string":       {v: "hello", want: "hello"},
 		"string with spaces": {v: "hello fencepost", want: `"hello fencepost"`},
 		"array":              {v: []string{"foo", "bar"}, want: `"[foo bar]"`},
-		"regexp":             {v: typicalChars, want: fmt.Sprintf("%q", typicalChars)},
+		"regexp":             {v: regexp.MustCompile(`^a b$`), want: `"^a b$"`},
 		"error":              {v: fmt.Errorf("this is a mistake"), want: `"this is a mistake"`},
 		"map":                {v: map[string]int{"a": 1, "b b": 2}, want: `"map[a:1 b b:2]"`},
 		/*
@@ -989,8 +989,8 @@ func Test_toString(t *testing.T) {
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			if got := toString(tt.v); got != tt.want {
-				t.Errorf("toString() = %v, want %v", got, tt.want)
+			if got := string(appendTextValue(nil, tt.v)); got != tt.want {
+				t.Errorf("appendTextValue() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -1047,8 +1047,9 @@ func Test_simpleLogger_doLog(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			buffer := &bytes.Buffer{}
 			sl := &simpleLogger{
-				writer: buffer,
-				lock:   &sync.RWMutex{},
+				writer:          buffer,
+				currentLogLevel: output.Info,
+				lock:            &sync.RWMutex{},
 			}
 			sl.doLog(output.Info, timestamp, tt.args.msg, tt.args.fields)
 			if got := buffer.String(); got != tt.want {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"strings"
 	"testing"
 
 	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
@@ -121,6 +122,69 @@ func TestInitLoggingWithLevel(t *testing.T) {
 	}
 }
 
+func TestInitLoggingWithLevel_format(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	tests := map[string]struct {
+		options    []cmdtoolkit.LogOption
+		wantPrefix string
+	}{
+		"default":    {options: nil, wantPrefix: "time="},
+		"text":       {options: []cmdtoolkit.LogOption{cmdtoolkit.WithLogFormat(cmdtoolkit.TextLogFormat)}, wantPrefix: "time="},
+		"json lines": {options: []cmdtoolkit.LogOption{cmdtoolkit.WithLogFormat(cmdtoolkit.JSONLinesLogFormat)}, wantPrefix: `{"time":`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+				return buffer, "testingLogPath"
+			}
+			if ok := cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "", tt.options...); !ok {
+				t.Fatalf("InitLoggingWithLevel() = false")
+			}
+			cmdtoolkit.ProductionLogger.Info("hello", map[string]any{"n": 1})
+			if got := buffer.String(); !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("InitLoggingWithLevel() logged %q, want prefix %q", got, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestInitLoggingWithLevel_sinks(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	file := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return file, "testingLogPath"
+	}
+	sink := &bytes.Buffer{}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Error, "", cmdtoolkit.WithLogSink(cmdtoolkit.LogSink{
+		Writer: sink,
+		Level:  output.Debug,
+		Format: cmdtoolkit.CompactLogFormat,
+	}))
+	cmdtoolkit.ProductionLogger.Debug("debugging", nil)
+	if got := file.String(); got != "" {
+		t.Errorf("log file got %q, want nothing", got)
+	}
+	if got, want := sink.String(), "debug: debugging\n"; got != want {
+		t.Errorf("sink got %q, want %q", got, want)
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Error, "")
+	cmdtoolkit.ProductionLogger.Error("failing", nil)
+	if got, want := sink.String(), "debug: debugging\n"; got != want {
+		t.Errorf("sink got %q after reinitialization, want %q", got, want)
+	}
+	if got := file.String(); !strings.HasSuffix(got, "level=error msg=failing\n") {
+		t.Errorf("log file got %q", got)
+	}
+}
+
 func TestErrorToString(t *testing.T) {
 	tests := map[string]struct {
 		e    error
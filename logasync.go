@@ -0,0 +1,225 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// the code in this file lets the logger hand its records to a goroutine that writes them to
+// the log file, so that logging does not wait on the file system
+
+// QueueFullPolicy specifies what the asynchronous writer does with a record when its queue
+// is full
+type QueueFullPolicy int
+
+const (
+	// BlockWhenQueueFull makes the logging goroutine wait until the queue has room for the
+	// record; no records are lost
+	BlockWhenQueueFull QueueFullPolicy = iota
+	// DropWhenQueueFull discards the record and counts it; logging never waits
+	DropWhenQueueFull
+)
+
+// DefaultLogQueueSize is the number of records queued by the asynchronous writer when
+// WithAsyncWriter is called with a queue size that is not positive
+const DefaultLogQueueSize = 1024
+
+var errLogWriterClosed = errors.New("the log writer is closed")
+
+type asyncSettings struct {
+	queueSize int
+	whenFull  QueueFullPolicy
+}
+
+// WithAsyncWriter makes the logger write to the log file through a queue holding up to
+// queueSize records, drained by a goroutine of its own; whenFull decides what happens to a
+// record logged while the queue is full. Queued records are written before Fatal exits,
+// before Panic panics, and before Flush and Close return
+func WithAsyncWriter(queueSize int, whenFull QueueFullPolicy) LogOption {
+	return func(sl *simpleLogger) {
+		if queueSize <= 0 {
+			queueSize = DefaultLogQueueSize
+		}
+		sl.async = asyncSettings{queueSize: queueSize, whenFull: whenFull}
+	}
+}
+
+// asyncItem is either a record to write or, if flushed is not nil, a request to be told
+// when every record queued before it has been written
+type asyncItem struct {
+	record  *recordBuffer
+	flushed chan struct{}
+}
+
+type asyncWriter struct {
+	target   io.Writer
+	whenFull QueueFullPolicy
+	queue    chan asyncItem
+	done     chan struct{}
+	dropped  atomic.Uint64
+	// lock guards closed; it is held for reading while sending to the queue, so that the
+	// queue cannot be closed by another goroutine in the meantime
+	lock   sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(target io.Writer, settings asyncSettings) *asyncWriter {
+	aw := &asyncWriter{
+		target:   target,
+		whenFull: settings.whenFull,
+		queue:    make(chan asyncItem, settings.queueSize),
+		done:     make(chan struct{}),
+	}
+	go aw.drain()
+	return aw
+}
+
+func (aw *asyncWriter) drain() {
+	defer close(aw.done)
+	for item := range aw.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		_, _ = aw.target.Write(item.record.b)
+		item.record.release()
+	}
+}
+
+// Write queues a copy of the record, as the caller may reuse p as soon as Write returns
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	aw.lock.RLock()
+	defer aw.lock.RUnlock()
+	if aw.closed {
+		return 0, errLogWriterClosed
+	}
+	record := getRecordBuffer()
+	record.b = append(record.b, p...)
+	item := asyncItem{record: record}
+	if aw.whenFull == DropWhenQueueFull {
+		select {
+		case aw.queue <- item:
+		default:
+			record.release()
+			aw.dropped.Add(1)
+		}
+		return len(p), nil
+	}
+	aw.queue <- item
+	return len(p), nil
+}
+
+// Flush waits until every record queued so far has been written; a flush request is never
+// dropped, even when the queue is full
+func (aw *asyncWriter) Flush() error {
+	aw.lock.RLock()
+	if aw.closed {
+		aw.lock.RUnlock()
+		return nil
+	}
+	flushed := make(chan struct{})
+	aw.queue <- asyncItem{flushed: flushed}
+	aw.lock.RUnlock()
+	<-flushed
+	return nil
+}
+
+// stop writes the queued records and ends the goroutine, leaving the target open
+func (aw *asyncWriter) stop() {
+	aw.lock.Lock()
+	if !aw.closed {
+		aw.closed = true
+		close(aw.queue)
+	}
+	aw.lock.Unlock()
+	<-aw.done
+}
+
+// Close writes the queued records, ends the goroutine, and closes the target
+func (aw *asyncWriter) Close() error {
+	aw.stop()
+	if closer, ok := aw.target.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// logFlusher is implemented by writers, such as the asynchronous writer and bufio.Writer,
+// that hold records until they are flushed
+type logFlusher interface {
+	Flush() error
+}
+
+// Flush writes any records held by the log file writer or by the sinks; it returns the
+// first error encountered
+func (sl *simpleLogger) Flush() error {
+	s := sl.state()
+	var flushErr error
+	// the asynchronous writer is flushed without the write lock, which its goroutine never
+	// needs, so that other goroutines may queue records in the meantime
+	aw, async := s.writer.(*asyncWriter)
+	if async {
+		flushErr = aw.Flush()
+	}
+	sl.writeLock.Lock()
+	defer sl.writeLock.Unlock()
+	if !async {
+		flushErr = flushWriter(s.writer)
+	}
+	for _, sink := range s.sinks {
+		if sinkErr := flushWriter(sink.Writer); flushErr == nil {
+			flushErr = sinkErr
+		}
+	}
+	return flushErr
+}
+
+func flushWriter(w io.Writer) error {
+	if flusher, ok := w.(logFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Close flushes the sinks and closes the log file, after writing any queued records;
+// records logged after Close are written only to the sinks
+func (sl *simpleLogger) Close() error {
+	sl.lock.Lock()
+	w := sl.writer
+	sl.writer = nil
+	sl.lock.Unlock()
+	closeErr := sl.Flush()
+	// acquiring the write lock waits for writes already under way to the log file
+	sl.writeLock.Lock()
+	defer sl.writeLock.Unlock()
+	if _, async := w.(*asyncWriter); !async {
+		if flushErr := flushWriter(w); closeErr == nil {
+			closeErr = flushErr
+		}
+	}
+	if closer, ok := w.(io.Closer); ok {
+		if fileErr := closer.Close(); closeErr == nil {
+			closeErr = fileErr
+		}
+	}
+	return closeErr
+}
+
+// DroppedLogRecords returns the number of records discarded because the asynchronous
+// writer's queue was full
+func (sl *simpleLogger) DroppedLogRecords() uint64 {
+	if aw, ok := sl.state().writer.(*asyncWriter); ok {
+		return aw.dropped.Load()
+	}
+	return 0
+}
+
+// stopAsyncWriter stops an asynchronous writer that is being replaced, so that its records
+// are not lost and its goroutine does not linger
+func stopAsyncWriter(w io.Writer) {
+	if aw, ok := w.(*asyncWriter); ok {
+		aw.stop()
+	}
+}
@@ -0,0 +1,202 @@
+package cmd_toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/majohn-r/output"
+)
+
+// gatedWriter announces each write on started and then waits for permission to finish it
+type gatedWriter struct {
+	bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+	closed  bool
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (gw *gatedWriter) Write(p []byte) (int, error) {
+	gw.started <- struct{}{}
+	<-gw.release
+	return gw.Buffer.Write(p)
+}
+
+func (gw *gatedWriter) Close() error {
+	gw.closed = true
+	return nil
+}
+
+func Test_asyncWriter_block(t *testing.T) {
+	target := &bytes.Buffer{}
+	aw := newAsyncWriter(target, asyncSettings{queueSize: 1, whenFull: BlockWhenQueueFull})
+	record := []byte("first\n")
+	_, _ = aw.Write(record)
+	// the writer must have copied the record
+	copy(record, "XXXXX\n")
+	for _, r := range []string{"second\n", "third\n"} {
+		_, _ = aw.Write([]byte(r))
+	}
+	if flushErr := aw.Flush(); flushErr != nil {
+		t.Errorf("asyncWriter.Flush() = %v", flushErr)
+	}
+	if got, want := target.String(), "first\nsecond\nthird\n"; got != want {
+		t.Errorf("asyncWriter wrote %q, want %q", got, want)
+	}
+	if got := aw.dropped.Load(); got != 0 {
+		t.Errorf("asyncWriter dropped %d records, want 0", got)
+	}
+	aw.stop()
+}
+
+func Test_asyncWriter_drop(t *testing.T) {
+	target := newGatedWriter()
+	aw := newAsyncWriter(target, asyncSettings{queueSize: 1, whenFull: DropWhenQueueFull})
+	_, _ = aw.Write([]byte("written\n"))
+	<-target.started
+	// the goroutine is busy with the first record, so the second fills the queue and the
+	// third is dropped
+	_, _ = aw.Write([]byte("queued\n"))
+	if n, writeErr := aw.Write([]byte("dropped\n")); n != len("dropped\n") || writeErr != nil {
+		t.Errorf("asyncWriter.Write() = %d, %v for a dropped record", n, writeErr)
+	}
+	if got := aw.dropped.Load(); got != 1 {
+		t.Errorf("asyncWriter dropped %d records, want 1", got)
+	}
+	close(target.release)
+	if closeErr := aw.Close(); closeErr != nil {
+		t.Errorf("asyncWriter.Close() = %v", closeErr)
+	}
+	if got, want := target.String(), "written\nqueued\n"; got != want {
+		t.Errorf("asyncWriter wrote %q, want %q", got, want)
+	}
+	if !target.closed {
+		t.Errorf("asyncWriter.Close() did not close its target")
+	}
+	if _, writeErr := aw.Write([]byte("late\n")); !errors.Is(writeErr, errLogWriterClosed) {
+		t.Errorf("asyncWriter.Write() after Close() = %v, want %v", writeErr, errLogWriterClosed)
+	}
+	if flushErr := aw.Flush(); flushErr != nil {
+		t.Errorf("asyncWriter.Flush() after Close() = %v", flushErr)
+	}
+}
+
+func Test_WithAsyncWriter(t *testing.T) {
+	tests := map[string]struct {
+		queueSize int
+		whenFull  QueueFullPolicy
+		want      asyncSettings
+	}{
+		"explicit size": {
+			queueSize: 5,
+			whenFull:  DropWhenQueueFull,
+			want:      asyncSettings{queueSize: 5, whenFull: DropWhenQueueFull},
+		},
+		"zero size": {
+			queueSize: 0,
+			whenFull:  BlockWhenQueueFull,
+			want:      asyncSettings{queueSize: DefaultLogQueueSize},
+		},
+		"negative size": {
+			queueSize: -1,
+			whenFull:  DropWhenQueueFull,
+			want:      asyncSettings{queueSize: DefaultLogQueueSize, whenFull: DropWhenQueueFull},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sl := &simpleLogger{}
+			WithAsyncWriter(tt.queueSize, tt.whenFull)(sl)
+			if sl.async != tt.want {
+				t.Errorf("WithAsyncWriter() = %+v, want %+v", sl.async, tt.want)
+			}
+		})
+	}
+}
+
+func Test_simpleLogger_FlushAndClose(t *testing.T) {
+	file := &bytes.Buffer{}
+	sinkTarget := &bytes.Buffer{}
+	sink := bufio.NewWriter(sinkTarget)
+	sl := &simpleLogger{
+		writer:          newAsyncWriter(file, asyncSettings{queueSize: 8}),
+		currentLogLevel: output.Info,
+		lock:            &sync.RWMutex{},
+	}
+	sl.addSink(LogSink{Writer: sink, Level: output.Info, Format: CompactLogFormat})
+	sl.Info("first", nil)
+	if flushErr := sl.Flush(); flushErr != nil {
+		t.Errorf("simpleLogger.Flush() = %v", flushErr)
+	}
+	if got := file.String(); !bytes.HasSuffix([]byte(got), []byte("level=info msg=first\n")) {
+		t.Errorf("simpleLogger.Flush() left log file %q", got)
+	}
+	if got, want := sinkTarget.String(), "info: first\n"; got != want {
+		t.Errorf("simpleLogger.Flush() left sink %q, want %q", got, want)
+	}
+	sl.Info("second", nil)
+	if closeErr := sl.Close(); closeErr != nil {
+		t.Errorf("simpleLogger.Close() = %v", closeErr)
+	}
+	if got := file.String(); !bytes.HasSuffix([]byte(got), []byte("level=info msg=second\n")) {
+		t.Errorf("simpleLogger.Close() left log file %q", got)
+	}
+	sl.Info("third", nil)
+	_ = sl.Flush()
+	if got := file.String(); bytes.Contains([]byte(got), []byte("third")) {
+		t.Errorf("simpleLogger wrote %q to the log file after Close()", got)
+	}
+	if got, want := sinkTarget.String(), "info: first\ninfo: second\ninfo: third\n"; got != want {
+		t.Errorf("sink got %q, want %q", got, want)
+	}
+}
+
+func Test_simpleLogger_DroppedLogRecords(t *testing.T) {
+	target := newGatedWriter()
+	aw := newAsyncWriter(target, asyncSettings{queueSize: 1, whenFull: DropWhenQueueFull})
+	sl := &simpleLogger{writer: aw, currentLogLevel: output.Info, lock: &sync.RWMutex{}}
+	sl.Info("written", nil)
+	<-target.started
+	sl.Info("queued", nil)
+	sl.Info("dropped", nil)
+	sl.Info("dropped", nil)
+	if got := sl.DroppedLogRecords(); got != 2 {
+		t.Errorf("simpleLogger.DroppedLogRecords() = %d, want 2", got)
+	}
+	close(target.release)
+	_ = sl.Close()
+	if got := sl.DroppedLogRecords(); got != 0 {
+		t.Errorf("simpleLogger.DroppedLogRecords() = %d after Close(), want 0", got)
+	}
+}
+
+func Test_simpleLogger_flushBeforeExit(t *testing.T) {
+	file := &bytes.Buffer{}
+	var atExit string
+	sl := &simpleLogger{
+		writer:          newAsyncWriter(file, asyncSettings{queueSize: 8}),
+		currentLogLevel: output.Info,
+		exitFunction:    func(int) { atExit = file.String() },
+		lock:            &sync.RWMutex{},
+	}
+	sl.Fatal("exiting", nil)
+	if !bytes.HasSuffix([]byte(atExit), []byte("level=fatal msg=exiting\n")) {
+		t.Errorf("simpleLogger.Fatal() exited with log file %q", atExit)
+	}
+	func() {
+		defer func() {
+			_ = recover()
+			if got := file.String(); !bytes.HasSuffix([]byte(got), []byte("level=panic msg=panicking\n")) {
+				t.Errorf("simpleLogger.Panic() panicked with log file %q", got)
+			}
+		}()
+		sl.Panic("panicking", nil)
+	}()
+	_ = sl.Close()
+}
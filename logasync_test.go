@@ -0,0 +1,63 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+func TestInitLoggingWithLevel_async(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	file := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return file, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "",
+		cmdtoolkit.WithAsyncWriter(16, cmdtoolkit.BlockWhenQueueFull))
+	for range 20 {
+		cmdtoolkit.ProductionLogger.Info("queued", nil)
+	}
+	if closeErr := cmdtoolkit.ProductionLogger.Close(); closeErr != nil {
+		t.Errorf("Close() = %v", closeErr)
+	}
+	if got := strings.Count(file.String(), "level=info msg=queued\n"); got != 20 {
+		t.Errorf("Close() left %d records in the log file, want 20", got)
+	}
+	if got := cmdtoolkit.ProductionLogger.DroppedLogRecords(); got != 0 {
+		t.Errorf("DroppedLogRecords() = %d, want 0", got)
+	}
+}
+
+func TestInitLoggingWithLevel_replacesAsyncWriter(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	first := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return first, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "", cmdtoolkit.WithAsyncWriter(0, cmdtoolkit.BlockWhenQueueFull))
+	cmdtoolkit.ProductionLogger.Info("before", nil)
+	second := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return second, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+	cmdtoolkit.ProductionLogger.Info("after", nil)
+	if got := first.String(); !strings.HasSuffix(got, "level=info msg=before\n") {
+		t.Errorf("replaced log writer got %q", got)
+	}
+	if got := second.String(); !strings.HasSuffix(got, "level=info msg=after\n") {
+		t.Errorf("new log writer got %q", got)
+	}
+}
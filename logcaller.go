@@ -0,0 +1,90 @@
+package cmd_toolkit
+
+import (
+	"maps"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// the code in this file adds the location of the code that logged a record to the record;
+// frames in the logging code itself, including this package, the output package's Bus, and
+// log/slog, are skipped, so that the location is that of the application code responsible
+
+const (
+	callerField   = "caller"
+	functionField = "function"
+	// maxCallerDepth is the number of frames searched for the caller
+	maxCallerDepth = 32
+)
+
+// toolkitPackage is the path of this package
+const toolkitPackage = "github.com/majohn-r/cmd-toolkit"
+
+// loggingPackages are the packages whose frames are skipped in looking for the caller
+var loggingPackages = []string{
+	toolkitPackage,
+	"github.com/majohn-r/output",
+	"log/slog",
+	"runtime",
+}
+
+// WithCaller adds the caller's location, as file:line, to each record, in a field named
+// caller, and the caller's function, in a field named function; fields with those names
+// passed by the caller are kept. The caller is the first function on the stack outside of
+// the logging code; skipPackages names additional packages, such as an application's own
+// logging helpers, to skip
+func WithCaller(skipPackages ...string) LogOption {
+	return func(sl *simpleLogger) {
+		sl.callerSkips = append(slices.Clone(loggingPackages), skipPackages...)
+	}
+}
+
+// addCaller returns the fields with the caller's location added
+func addCaller(fields map[string]any, skips []string) map[string]any {
+	var pcs [maxCallerDepth]uintptr
+	// skip runtime.Callers and addCaller
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLoggingFrame(frame, skips) {
+			withCaller := make(map[string]any, len(fields)+2)
+			withCaller[callerField] = shortFileName(frame.File) + ":" + strconv.Itoa(frame.Line)
+			withCaller[functionField] = frame.Function
+			maps.Copy(withCaller, fields)
+			return withCaller
+		}
+		if !more {
+			return fields
+		}
+	}
+}
+
+// isLoggingFrame determines whether a frame belongs to one of the skipped packages
+func isLoggingFrame(frame runtime.Frame, skips []string) bool {
+	return slices.Contains(skips, functionPackage(frame.Function))
+}
+
+// functionPackage returns the path of the package of a fully qualified function name, such
+// as github.com/majohn-r/output.(*bus).Log
+func functionPackage(function string) string {
+	lastSlash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[lastSlash+1:], '.')
+	if dot < 0 {
+		return function
+	}
+	return function[:lastSlash+1+dot]
+}
+
+// shortFileName returns the file name with its directory, e.g., cmd/list.go
+func shortFileName(file string) string {
+	dir, name := filepath.Split(filepath.ToSlash(file))
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		return name
+	}
+	return dir[strings.LastIndexByte(dir, '/')+1:] + "/" + name
+}
@@ -0,0 +1,111 @@
+package cmd_toolkit
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/majohn-r/output"
+)
+
+func Test_functionPackage(t *testing.T) {
+	tests := map[string]string{
+		"github.com/majohn-r/output.(*bus).Log":                 "github.com/majohn-r/output",
+		"github.com/majohn-r/cmd-toolkit.(*simpleLogger).log":   "github.com/majohn-r/cmd-toolkit",
+		"github.com/majohn-r/cmd-toolkit_test.TestX.func1":      "github.com/majohn-r/cmd-toolkit_test",
+		"log/slog.(*Logger).log":                                "log/slog",
+		"main.main":                                             "main",
+		"runtime.goexit":                                        "runtime",
+		"example.com/app/v2/internal/pkg.Type[...].Method.func": "example.com/app/v2/internal/pkg",
+		"noPackage": "noPackage",
+	}
+	for function, want := range tests {
+		t.Run(function, func(t *testing.T) {
+			if got := functionPackage(function); got != want {
+				t.Errorf("functionPackage() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_isLoggingFrame(t *testing.T) {
+	tests := map[string]struct {
+		frame runtime.Frame
+		want  bool
+	}{
+		"toolkit": {
+			frame: runtime.Frame{Function: "github.com/majohn-r/cmd-toolkit.(*simpleLogger).log", File: "log.go"},
+			want:  true,
+		},
+		"toolkit test file": {
+			frame: runtime.Frame{Function: "github.com/majohn-r/cmd-toolkit.Test_x", File: "log_internals_test.go"},
+			want:  true,
+		},
+		"external tests": {
+			frame: runtime.Frame{Function: "github.com/majohn-r/cmd-toolkit_test.TestX", File: "log_test.go"},
+			want:  false,
+		},
+		"application": {
+			frame: runtime.Frame{Function: "main.main", File: "main.go"},
+			want:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isLoggingFrame(tt.frame, loggingPackages); got != tt.want {
+				t.Errorf("isLoggingFrame() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shortFileName(t *testing.T) {
+	tests := map[string]string{
+		"C:/src/app/cmd/list.go": "cmd/list.go",
+		"/src/app/main.go":       "app/main.go",
+		"/main.go":               "main.go",
+		"main.go":                "main.go",
+	}
+	for file, want := range tests {
+		t.Run(file, func(t *testing.T) {
+			if got := shortFileName(file); got != want {
+				t.Errorf("shortFileName() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func benchmarkLogger(callerSkips []string) *simpleLogger {
+	return &simpleLogger{
+		writer:          io.Discard,
+		currentLogLevel: output.Info,
+		callerSkips:     callerSkips,
+		lock:            &sync.RWMutex{},
+	}
+}
+
+func BenchmarkSimpleLogger_Info(b *testing.B) {
+	fields := map[string]any{"command": "list", "count": 3}
+	b.Run("without caller", func(b *testing.B) {
+		sl := benchmarkLogger(nil)
+		b.ReportAllocs()
+		for b.Loop() {
+			sl.Info("message", fields)
+		}
+	})
+	b.Run("with caller", func(b *testing.B) {
+		sl := benchmarkLogger(loggingPackages)
+		b.ReportAllocs()
+		for b.Loop() {
+			sl.Info("message", fields)
+		}
+	})
+	b.Run("with caller through a bus", func(b *testing.B) {
+		o := output.NewDefaultBus(benchmarkLogger(loggingPackages))
+		b.ReportAllocs()
+		for b.Loop() {
+			o.Log(output.Info, "message", fields)
+		}
+	})
+}
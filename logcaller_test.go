@@ -0,0 +1,122 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+// logHelper stands in for an application's own logging helper
+func logHelper(o output.Bus, msg string) {
+	o.Log(output.Info, msg, nil)
+}
+
+func TestWithCaller(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	buffer := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return buffer, "testingLogPath"
+	}
+	here := func() string {
+		_, file, line, _ := runtime.Caller(1)
+		return file[strings.LastIndex(file, "/")+1:] + ":" + strconv.Itoa(line+1)
+	}
+	const function = "github.com/majohn-r/cmd-toolkit_test.TestWithCaller"
+	tests := map[string]struct {
+		skips        []string
+		log          func() string
+		wantFunction string
+	}{
+		"direct": {
+			log: func() string {
+				location := here()
+				cmdtoolkit.ProductionLogger.Info("direct", nil)
+				return location
+			},
+			wantFunction: function + ".func",
+		},
+		"through a bus": {
+			log: func() string {
+				o := output.NewDefaultBus(cmdtoolkit.ProductionLogger)
+				location := here()
+				o.Log(output.Info, "bus", nil)
+				return location
+			},
+			wantFunction: function + ".func",
+		},
+		"through a bus with fields": {
+			log: func() string {
+				o := cmdtoolkit.BusWith(output.NewDefaultBus(cmdtoolkit.ProductionLogger), map[string]any{"k": "v"})
+				location := here()
+				o.Log(output.Info, "bound bus", nil)
+				return location
+			},
+			wantFunction: function + ".func",
+		},
+		"through slog": {
+			log: func() string {
+				l := slog.New(cmdtoolkit.NewSlogHandler(cmdtoolkit.ProductionLogger))
+				location := here()
+				l.Info("slog")
+				return location
+			},
+			wantFunction: function + ".func",
+		},
+		"through a skipped helper": {
+			skips: []string{"github.com/majohn-r/cmd-toolkit_test"},
+			log: func() string {
+				logHelper(output.NewDefaultBus(cmdtoolkit.ProductionLogger), "helper")
+				return ""
+			},
+			wantFunction: "testing.tRunner",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buffer.Reset()
+			cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "",
+				cmdtoolkit.WithLogFormat(cmdtoolkit.JSONLinesLogFormat),
+				cmdtoolkit.WithCaller(tt.skips...))
+			location := tt.log()
+			record, parseErr := cmdtoolkit.ParseLogRecord(buffer.String())
+			if parseErr != nil {
+				t.Fatalf("ParseLogRecord(%q) error = %v", buffer.String(), parseErr)
+			}
+			if location != "" && !strings.HasSuffix(record.Fields["caller"].(string), "/"+location) {
+				t.Errorf("caller = %v, want suffix %q", record.Fields["caller"], location)
+			}
+			if got, _ := record.Fields["function"].(string); !strings.HasPrefix(got, tt.wantFunction) {
+				t.Errorf("function = %q, want prefix %q", got, tt.wantFunction)
+			}
+		})
+	}
+}
+
+func TestWithCaller_off(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	buffer := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return buffer, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "", cmdtoolkit.WithCaller())
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+	cmdtoolkit.ProductionLogger.Info("plain", map[string]any{"caller": "mine"})
+	if got := buffer.String(); !strings.HasSuffix(got, "level=info msg=plain caller=mine\n") {
+		t.Errorf("logged %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package cmd_toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// the code in this file keeps processes that share a log directory, such as two instances
+// of an application, or an elevated instance and the instance that started it, from
+// cleaning it up at the same time
+
+const (
+	cleanupLockSuffix = "cleanup.lock"
+	// staleCleanupLockAge is the age beyond which a cleanup lock is presumed to have been
+	// abandoned by a process that ended without removing it
+	staleCleanupLockAge = 10 * time.Minute
+	// logFileSettlingTime is how long after the end of its day that a log file may still
+	// receive records, from a process that formatted them just before midnight
+	logFileSettlingTime = time.Minute
+)
+
+func cleanupLockFile(logPath, applicationName string) string {
+	return filepath.Join(logPath, logFilePrefix(applicationName)+cleanupLockSuffix)
+}
+
+// lockCleanup creates the log directory's cleanup lock file, returning a function that
+// removes it; busy is true if another process holds the lock. If the lock file cannot be
+// created for any other reason, such as a missing directory, cleanup goes ahead without it
+// and reports the underlying problem in the usual way
+func lockCleanup(logPath, applicationName string) (release func(), busy bool) {
+	release = func() {}
+	if !DirExists(logPath) {
+		return
+	}
+	lockFile := cleanupLockFile(logPath, applicationName)
+	// the second attempt follows the removal of a stale lock
+	for range 2 {
+		f, createErr := fileSystem.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, StdFilePermissions)
+		if createErr == nil {
+			// the process ID is there for the benefit of anyone investigating a stale lock
+			_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+			_ = f.Close()
+			return func() { _ = fileSystem.Remove(lockFile) }, false
+		}
+		if !errors.Is(createErr, fs.ErrExist) {
+			return
+		}
+		info, statErr := fileSystem.Stat(lockFile)
+		if statErr == nil && (!isStale(info) || !takeOverStaleLock(lockFile)) {
+			return release, true
+		}
+	}
+	return release, true
+}
+
+func isStale(info fs.FileInfo) bool {
+	return time.Since(info.ModTime()) >= staleCleanupLockAge
+}
+
+// takeOverStaleLock removes a lock file found to be stale, returning false if another
+// process has meanwhile taken it over, or replaced it with a lock of its own. The lock file
+// is renamed before it is checked again and removed, so that a lock just created by another
+// process is never removed in its place
+func takeOverStaleLock(lockFile string) bool {
+	claimed := fmt.Sprintf("%s.%d", lockFile, os.Getpid())
+	if fileSystem.Rename(lockFile, claimed) != nil {
+		return false
+	}
+	info, statErr := fileSystem.Stat(claimed)
+	if statErr == nil && !isStale(info) {
+		restoreLock(claimed, lockFile)
+		return false
+	}
+	_ = fileSystem.Remove(claimed)
+	return true
+}
+
+// restoreLock puts back another process's lock, which takeOverStaleLock moved aside, unless
+// a third process has created a lock in the meantime
+func restoreLock(claimed, lockFile string) {
+	defer func() { _ = fileSystem.Remove(claimed) }()
+	content, readErr := afero.ReadFile(fileSystem, claimed)
+	if readErr != nil {
+		return
+	}
+	f, createErr := fileSystem.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, StdFilePermissions)
+	if createErr != nil {
+		return
+	}
+	_, _ = f.Write(content)
+	_ = f.Close()
+}
@@ -0,0 +1,176 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func Test_lockCleanup(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	lockFile := cleanupLockFile("logs", "app")
+	tests := map[string]struct {
+		preTest      func()
+		path         string
+		wantBusy     bool
+		wantAcquired bool
+	}{
+		"missing directory": {
+			preTest: func() {},
+			path:    "no such directory",
+		},
+		"unlocked": {
+			preTest: func() {
+				_ = fileSystem.Mkdir("logs", StdDirPermissions)
+			},
+			path:         "logs",
+			wantAcquired: true,
+		},
+		"locked": {
+			preTest: func() {
+				_ = fileSystem.Mkdir("logs", StdDirPermissions)
+				_ = afero.WriteFile(fileSystem, lockFile, []byte("1\n"), StdFilePermissions)
+			},
+			path:     "logs",
+			wantBusy: true,
+		},
+		"stale lock": {
+			preTest: func() {
+				_ = fileSystem.Mkdir("logs", StdDirPermissions)
+				_ = afero.WriteFile(fileSystem, lockFile, []byte("1\n"), StdFilePermissions)
+				abandoned := time.Now().Add(-2 * staleCleanupLockAge)
+				_ = fileSystem.Chtimes(lockFile, abandoned, abandoned)
+			},
+			path:         "logs",
+			wantAcquired: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fileSystem = afero.NewMemMapFs()
+			tt.preTest()
+			release, busy := lockCleanup(tt.path, "app")
+			if busy != tt.wantBusy {
+				t.Errorf("lockCleanup() busy = %t, want %t", busy, tt.wantBusy)
+			}
+			if tt.wantAcquired {
+				content, _ := afero.ReadFile(fileSystem, lockFile)
+				if want := fmt.Sprintf("%d\n", os.Getpid()); string(content) != want {
+					t.Errorf("lockCleanup() lock file contains %q, want %q", content, want)
+				}
+				if _, busyAgain := lockCleanup(tt.path, "app"); !busyAgain {
+					t.Errorf("lockCleanup() acquired a lock that is already held")
+				}
+			}
+			release()
+			if exists, _ := afero.Exists(fileSystem, lockFile); exists != tt.wantBusy {
+				t.Errorf("lockCleanup() lock file exists = %t after release, want %t", exists, tt.wantBusy)
+			}
+			if exists, _ := afero.Exists(fileSystem, tt.path); !exists && tt.path == "logs" {
+				t.Errorf("lockCleanup() removed the directory")
+			}
+		})
+	}
+}
+
+func Test_takeOverStaleLock(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	lockFile := cleanupLockFile("logs", "app")
+	claimed := fmt.Sprintf("%s.%d", lockFile, os.Getpid())
+	abandoned := time.Now().Add(-2 * staleCleanupLockAge)
+	tests := map[string]struct {
+		preTest      func()
+		want         bool
+		wantLock     bool
+		wantContents string
+	}{
+		"stale lock": {
+			preTest: func() {
+				_ = afero.WriteFile(fileSystem, lockFile, []byte("1\n"), StdFilePermissions)
+				_ = fileSystem.Chtimes(lockFile, abandoned, abandoned)
+			},
+			want: true,
+		},
+		"already taken over": {
+			preTest: func() {},
+			want:    false,
+		},
+		"replaced by a fresh lock": {
+			preTest: func() {
+				_ = afero.WriteFile(fileSystem, lockFile, []byte("2\n"), StdFilePermissions)
+			},
+			want:         false,
+			wantLock:     true,
+			wantContents: "2\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fileSystem = afero.NewMemMapFs()
+			_ = fileSystem.Mkdir("logs", StdDirPermissions)
+			tt.preTest()
+			if got := takeOverStaleLock(lockFile); got != tt.want {
+				t.Errorf("takeOverStaleLock() = %t, want %t", got, tt.want)
+			}
+			content, readErr := afero.ReadFile(fileSystem, lockFile)
+			if (readErr == nil) != tt.wantLock || string(content) != tt.wantContents {
+				t.Errorf("takeOverStaleLock() left lock %q (%v), want %q", content, readErr, tt.wantContents)
+			}
+			if exists, _ := afero.Exists(fileSystem, claimed); exists {
+				t.Errorf("takeOverStaleLock() left %q behind", claimed)
+			}
+		})
+	}
+}
+
+func Test_restoreLock(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	_ = fileSystem.Mkdir("logs", StdDirPermissions)
+	lockFile := cleanupLockFile("logs", "app")
+	claimed := lockFile + ".1"
+	_ = afero.WriteFile(fileSystem, claimed, []byte("2\n"), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, lockFile, []byte("3\n"), StdFilePermissions)
+	restoreLock(claimed, lockFile)
+	if content, _ := afero.ReadFile(fileSystem, lockFile); string(content) != "3\n" {
+		t.Errorf("restoreLock() replaced a newer lock: lock contains %q, want %q", content, "3\n")
+	}
+	if exists, _ := afero.Exists(fileSystem, claimed); exists {
+		t.Errorf("restoreLock() left %q behind", claimed)
+	}
+}
+
+func Test_cleanup_locked(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	_ = fileSystem.Mkdir("logs", StdDirPermissions)
+	_ = afero.WriteFile(fileSystem, cleanupLockFile("logs", "app"), []byte("1\n"), StdFilePermissions)
+	for _, name := range []string{"app.a.log", "app.b.log", "app.c.log"} {
+		_ = afero.WriteFile(fileSystem, filepath.Join("logs", name), []byte("records"), StdFilePermissions)
+	}
+	o := output.NewRecorder()
+	if found, deleted := cleanup(o, "logs", "app", RetentionPolicy{MaxFiles: 1}); found != 0 || deleted != 0 {
+		t.Errorf("cleanup() = %d, %d, want 0, 0", found, deleted)
+	}
+	if entries, _ := afero.ReadDir(fileSystem, "logs"); len(entries) != 4 {
+		t.Errorf("cleanup() left %d entries, want 4", len(entries))
+	}
+	o.Report(t, "cleanup()", output.WantedRecording{})
+}
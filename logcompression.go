@@ -0,0 +1,95 @@
+package cmd_toolkit
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+// the code in this file compresses the log files of past days, which are no longer written
+// to, with gzip; a compressed file keeps its name, with .gz appended, so that it keeps its
+// place in the order of log files
+
+const compressedLogFileExtension = logFileExtension + ".gz"
+
+// compressOldLogFiles compresses the uncompressed log files whose names date them before
+// the current day, returning the number of files compressed; a file is left alone until its
+// day has been over for logFileSettlingTime, in case another process is still writing to it
+func compressOldLogFiles(o output.Bus, logPath, applicationName string, files []fs.FileInfo, now time.Time) int {
+	settled := now.Add(-logFileSettlingTime)
+	compressed := 0
+	for _, file := range files {
+		if !isLogFile(file, applicationName) {
+			continue
+		}
+		lf := newLogFile(file, logFilePrefix(applicationName))
+		if !lf.dated || lf.compressed || lf.ended().After(settled) {
+			continue
+		}
+		logFile := filepath.Join(logPath, lf.name)
+		if compressErr := compressLogFile(logFile, file.ModTime()); compressErr != nil {
+			o.ErrorPrintf("The log file %q cannot be compressed: %s.\n", logFile, ErrorToString(compressErr))
+			continue
+		}
+		compressed++
+	}
+	return compressed
+}
+
+// compressLogFile replaces a log file with a gzip-compressed copy
+func compressLogFile(logFile string, modified time.Time) error {
+	source, openErr := fileSystem.Open(logFile)
+	if openErr != nil {
+		return openErr
+	}
+	target := logFile + ".gz"
+	destination, createErr := fileSystem.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, StdFilePermissions)
+	if createErr != nil {
+		_ = source.Close()
+		return createErr
+	}
+	gz := gzip.NewWriter(destination)
+	gz.Name = filepath.Base(logFile)
+	gz.ModTime = modified
+	_, copyErr := io.Copy(gz, source)
+	writeErr := errors.Join(copyErr, gz.Close(), destination.Close())
+	_ = source.Close()
+	if writeErr != nil {
+		_ = fileSystem.Remove(target)
+		return writeErr
+	}
+	_ = fileSystem.Chtimes(target, modified, modified)
+	if removeErr := fileSystem.Remove(logFile); removeErr != nil {
+		// keep the original, rather than two copies of the same records
+		_ = fileSystem.Remove(target)
+		return removeErr
+	}
+	return nil
+}
+
+// removeDanglingSymlink removes the symlink to the latest log file if the file that it
+// links to is gone, e.g., because it has been compressed; the log writer replaces a
+// symlink to an existing file, but cannot replace one that dangles
+func removeDanglingSymlink(symlink string) {
+	reader, ok := fileSystem.(afero.LinkReader)
+	if !ok {
+		return
+	}
+	target, readErr := reader.ReadlinkIfPossible(symlink)
+	if readErr != nil {
+		return
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(symlink), target)
+	}
+	if _, statErr := fileSystem.Stat(target); errors.Is(statErr, fs.ErrNotExist) {
+		_ = fileSystem.Remove(symlink)
+	}
+}
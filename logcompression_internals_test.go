@@ -0,0 +1,144 @@
+package cmd_toolkit
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func Test_compressOldLogFiles(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	now := time.Now()
+	dated := func(daysAgo int) string {
+		return "app." + now.AddDate(0, 0, -daysAgo).Format(logFileDateLayout) + logFileExtension
+	}
+	contents := map[string]string{
+		dated(0):          "today's records\n",
+		dated(1):          "yesterday's records\n",
+		dated(9):          "older records\n",
+		dated(12) + ".gz": "already compressed",
+		"app.misc.log":    "undated records\n",
+		"other.log":       "another application's records\n",
+	}
+	_ = fileSystem.Mkdir("logs", StdDirPermissions)
+	for name, content := range contents {
+		_ = afero.WriteFile(fileSystem, filepath.Join("logs", name), []byte(content), StdFilePermissions)
+	}
+	files, _ := ReadDirectory(output.NewNilBus(), "logs")
+	o := output.NewRecorder()
+	if got := compressOldLogFiles(o, "logs", "app", files, now); got != 2 {
+		t.Errorf("compressOldLogFiles() = %d, want 2", got)
+	}
+	o.Report(t, "compressOldLogFiles()", output.WantedRecording{})
+	entries, _ := afero.ReadDir(fileSystem, "logs")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	wantNames := []string{dated(0), dated(1) + ".gz", dated(9) + ".gz", dated(12) + ".gz", "app.misc.log", "other.log"}
+	slices.Sort(wantNames)
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("compressOldLogFiles() left %v, want %v", names, wantNames)
+	}
+	for _, name := range []string{dated(1), dated(9)} {
+		f, _ := fileSystem.Open(filepath.Join("logs", name+".gz"))
+		r, readerErr := gzip.NewReader(f)
+		if readerErr != nil {
+			t.Errorf("compressOldLogFiles() %s is not compressed: %v", name, readerErr)
+			_ = f.Close()
+			continue
+		}
+		got, _ := io.ReadAll(r)
+		if string(got) != contents[name] {
+			t.Errorf("compressOldLogFiles() %s contains %q, want %q", name, got, contents[name])
+		}
+		if r.Name != name {
+			t.Errorf("compressOldLogFiles() %s header name %q", name, r.Name)
+		}
+		_ = f.Close()
+	}
+}
+
+func Test_compressOldLogFiles_failure(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	memory := afero.NewMemMapFs()
+	fileName := "app." + time.Now().AddDate(0, 0, -1).Format(logFileDateLayout) + logFileExtension
+	_ = memory.Mkdir("logs", StdDirPermissions)
+	_ = afero.WriteFile(memory, filepath.Join("logs", fileName), []byte("records"), StdFilePermissions)
+	files, _ := afero.ReadDir(memory, "logs")
+	fileSystem = afero.NewReadOnlyFs(memory)
+	o := output.NewRecorder()
+	if got := compressOldLogFiles(o, "logs", "app", files, time.Now()); got != 0 {
+		t.Errorf("compressOldLogFiles() = %d, want 0", got)
+	}
+	o.Report(t, "compressOldLogFiles()", output.WantedRecording{
+		Error: "The log file \"" + filepath.Join("logs", fileName) + "\" cannot be compressed: " +
+			"'syscall.Errno: operation not permitted'.\n",
+	})
+	if exists, _ := afero.Exists(memory, filepath.Join("logs", fileName)); !exists {
+		t.Errorf("compressOldLogFiles() removed %s", fileName)
+	}
+}
+
+func Test_compressOldLogFiles_settling(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	midnight := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.Local)
+	fileName := "app." + midnight.AddDate(0, 0, -1).Format(logFileDateLayout) + logFileExtension
+	_ = fileSystem.Mkdir("logs", StdDirPermissions)
+	_ = afero.WriteFile(fileSystem, filepath.Join("logs", fileName), []byte("records"), StdFilePermissions)
+	files, _ := ReadDirectory(output.NewNilBus(), "logs")
+	o := output.NewRecorder()
+	if got := compressOldLogFiles(o, "logs", "app", files, midnight.Add(logFileSettlingTime/2)); got != 0 {
+		t.Errorf("compressOldLogFiles() = %d just after midnight, want 0", got)
+	}
+	if got := compressOldLogFiles(o, "logs", "app", files, midnight.Add(2*logFileSettlingTime)); got != 1 {
+		t.Errorf("compressOldLogFiles() = %d once the file has settled, want 1", got)
+	}
+	o.Report(t, "compressOldLogFiles()", output.WantedRecording{})
+}
+
+func Test_removeDanglingSymlink(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewOsFs()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.20240102.log")
+	symlink := filepath.Join(dir, symlinkName)
+	_ = os.WriteFile(target, []byte("records"), StdFilePermissions)
+	if symlinkErr := os.Symlink(target, symlink); symlinkErr != nil {
+		t.Skipf("symbolic links cannot be created: %v", symlinkErr)
+	}
+	removeDanglingSymlink(symlink)
+	if _, statErr := os.Lstat(symlink); statErr != nil {
+		t.Errorf("removeDanglingSymlink() removed a valid symlink")
+	}
+	_ = os.Remove(target)
+	removeDanglingSymlink(symlink)
+	if _, statErr := os.Lstat(symlink); statErr == nil {
+		t.Errorf("removeDanglingSymlink() kept a dangling symlink")
+	}
+	// neither a missing symlink nor a file system without symlinks is a problem
+	removeDanglingSymlink(symlink)
+	fileSystem = afero.NewMemMapFs()
+	removeDanglingSymlink(symlink)
+}
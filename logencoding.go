@@ -0,0 +1,245 @@
+package cmd_toolkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file renders log records into reusable buffers, appending to byte slices
+// rather than building strings, so that logging the common field types allocates nothing
+
+// maxPooledRecordSize is the capacity beyond which a record buffer is not reused, so that a
+// rare, huge record does not pin its memory indefinitely
+const maxPooledRecordSize = 64 * 1024
+
+// recordBuffer holds a rendered record, with its trailing newline, and scratch space for
+// sorting the record's field keys
+type recordBuffer struct {
+	b    []byte
+	keys []string
+}
+
+var recordBufferPool = sync.Pool{
+	New: func() any {
+		return &recordBuffer{b: make([]byte, 0, 1024), keys: make([]string, 0, 16)}
+	},
+}
+
+func getRecordBuffer() *recordBuffer {
+	return recordBufferPool.Get().(*recordBuffer)
+}
+
+func (rb *recordBuffer) release() {
+	if cap(rb.b) > maxPooledRecordSize {
+		return
+	}
+	rb.b = rb.b[:0]
+	clear(rb.keys)
+	rb.keys = rb.keys[:0]
+	recordBufferPool.Put(rb)
+}
+
+// sortedKeys returns the keys of the fields, sorted, using the buffer's scratch space
+func (rb *recordBuffer) sortedKeys(fields map[string]any) []string {
+	for k := range fields {
+		rb.keys = append(rb.keys, k)
+	}
+	slices.Sort(rb.keys)
+	return rb.keys
+}
+
+// render renders a record, followed by a newline, in the specified format
+func (rb *recordBuffer) render(f LogFormat, l output.Level, timestamp time.Time, msg string, fields map[string]any) {
+	switch f {
+	case JSONLinesLogFormat:
+		rb.appendJSONRecord(l, timestamp, msg, fields)
+	case CompactLogFormat:
+		rb.appendCompactRecord(l, msg, fields)
+	default:
+		rb.appendTextRecord(l, timestamp, msg, fields)
+	}
+	rb.b = append(rb.b, '\n')
+}
+
+func (rb *recordBuffer) appendTextRecord(l output.Level, timestamp time.Time, msg string, fields map[string]any) {
+	rb.b = append(rb.b, `time="`...)
+	rb.b = timestamp.AppendFormat(rb.b, time.RFC3339)
+	rb.b = append(rb.b, `" level=`...)
+	rb.b = append(rb.b, levelsToString[l]...)
+	rb.b = append(rb.b, " msg="...)
+	rb.b = appendTextString(rb.b, msg)
+	for _, k := range rb.sortedKeys(fields) {
+		rb.b = append(rb.b, ' ')
+		rb.b = append(rb.b, k...)
+		rb.b = append(rb.b, '=')
+		rb.b = appendTextValue(rb.b, fields[k])
+	}
+}
+
+func (rb *recordBuffer) appendCompactRecord(l output.Level, msg string, fields map[string]any) {
+	rb.b = append(rb.b, levelsToString[l]...)
+	rb.b = append(rb.b, ": "...)
+	rb.b = appendCompactMessage(rb.b, msg)
+	for _, k := range rb.sortedKeys(fields) {
+		rb.b = append(rb.b, ' ')
+		rb.b = append(rb.b, k...)
+		rb.b = append(rb.b, '=')
+		rb.b = appendTextValue(rb.b, fields[k])
+	}
+}
+
+func (rb *recordBuffer) appendJSONRecord(l output.Level, timestamp time.Time, msg string, fields map[string]any) {
+	rb.b = append(rb.b, `{"time":"`...)
+	rb.b = timestamp.AppendFormat(rb.b, time.RFC3339)
+	rb.b = append(rb.b, `","level":`...)
+	rb.b = appendJSONString(rb.b, levelsToString[l])
+	rb.b = append(rb.b, `,"msg":`...)
+	rb.b = appendJSONString(rb.b, msg)
+	for _, k := range rb.sortedKeys(fields) {
+		rb.b = append(rb.b, ',')
+		rb.b = appendJSONString(rb.b, k)
+		rb.b = append(rb.b, ':')
+		rb.b = appendJSONValue(rb.b, fields[k])
+	}
+	rb.b = append(rb.b, '}')
+}
+
+// appendTextValue appends a value in the text format: integers and booleans as they are, and
+// anything else as a string, quoted if it holds anything but typical characters
+func appendTextValue(b []byte, v any) []byte {
+	switch value := v.(type) {
+	case string:
+		return appendTextString(b, value)
+	case int:
+		return strconv.AppendInt(b, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(b, value, 10)
+	case int32:
+		return strconv.AppendInt(b, int64(value), 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(value), 10)
+	case uint64:
+		return strconv.AppendUint(b, value, 10)
+	case bool:
+		return strconv.AppendBool(b, value)
+	default:
+		return appendTextString(b, fmt.Sprint(v))
+	}
+}
+
+// appendTextString appends a string, quoted if it holds anything but typical characters
+func appendTextString(b []byte, s string) []byte {
+	if requiresQuotes(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+// appendCompactMessage appends a message unquoted, for the sake of readability, but with its
+// control characters, line separators, and invalid bytes escaped as Go escapes them, so that a
+// message cannot break its record into lines that pass for records of their own
+func appendCompactMessage(b []byte, msg string) []byte {
+	for i := 0; i < len(msg); {
+		r, size := utf8.DecodeRuneInString(msg[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, `\x`...)
+			b = append(b, lowerHex[msg[i]>>4], lowerHex[msg[i]&0xF])
+		case strconv.IsGraphic(r):
+			b = append(b, msg[i:i+size]...)
+		default:
+			// the rune is quoted, e.g., '\n', and the quotes are then dropped
+			start := len(b)
+			b = strconv.AppendQuoteRune(b, r)
+			b = append(b[:start], b[start+1:len(b)-1]...)
+		}
+		i += size
+	}
+	return b
+}
+
+// appendJSONValue appends the JSON encoding of a value, as json.Marshal encodes jsonCompatible(v)
+func appendJSONValue(b []byte, v any) []byte {
+	switch value := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case string:
+		return appendJSONString(b, value)
+	case int:
+		return strconv.AppendInt(b, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(b, value, 10)
+	case int32:
+		return strconv.AppendInt(b, int64(value), 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(value), 10)
+	case uint64:
+		return strconv.AppendUint(b, value, 10)
+	case bool:
+		return strconv.AppendBool(b, value)
+	default:
+		encoded, encodeErr := json.Marshal(jsonCompatible(v))
+		if encodeErr != nil {
+			return appendJSONString(b, fmt.Sprint(v))
+		}
+		return append(b, encoded...)
+	}
+}
+
+const lowerHex = "0123456789abcdef"
+
+// appendJSONString appends a string encoded exactly as encoding/json encodes it, including
+// its escaping of the HTML characters <, >, and &
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', lowerHex[c>>4], lowerHex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			start = i + size
+		case r == '\u2028' || r == '\u2029':
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', lowerHex[r&0xF])
+			start = i + size
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
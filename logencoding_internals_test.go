@@ -0,0 +1,176 @@
+package cmd_toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+func Test_appendJSONString(t *testing.T) {
+	tests := map[string]string{
+		"empty":            "",
+		"plain":            "hello fencepost",
+		"quotes":           `say "hello" \ goodbye`,
+		"controls":         "\b\f\n\r\t\x00\x1f\x7f",
+		"html":             "<a href=\"x\">&amp;</a>",
+		"non-ascii":        "naïve café ☕",
+		"invalid utf-8":    "bad \xff\xfe bytes",
+		"line separators":  "one\u2028two\u2029three",
+		"truncated rune":   "\xe2\x82",
+		"trailing escapes": "end\n",
+	}
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			want, _ := json.Marshal(s)
+			if got := appendJSONString(nil, s); string(got) != string(want) {
+				t.Errorf("appendJSONString() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Test_appendJSONValue(t *testing.T) {
+	tests := map[string]struct {
+		v    any
+		want string
+	}{
+		"nil":          {v: nil, want: "null"},
+		"string":       {v: "a<b", want: `"a\u003cb"`},
+		"int":          {v: -45, want: "-45"},
+		"int64":        {v: int64(math.MinInt64), want: "-9223372036854775808"},
+		"int32":        {v: int32(7), want: "7"},
+		"uint":         {v: uint(8), want: "8"},
+		"uint64":       {v: uint64(math.MaxUint64), want: "18446744073709551615"},
+		"bool":         {v: true, want: "true"},
+		"float":        {v: 1.5, want: "1.5"},
+		"error":        {v: errors.New("failed"), want: `"failed"`},
+		"nested error": {v: map[string]any{"e": errors.New("x")}, want: `{"e":"x"}`},
+		"unencodable":  {v: math.Inf(1), want: `"+Inf"`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := appendJSONValue(nil, tt.v); string(got) != tt.want {
+				t.Errorf("appendJSONValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordBuffer_render(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	fields := map[string]any{"b": "two words", "a": 1}
+	tests := map[string]struct {
+		f    LogFormat
+		want string
+	}{
+		"text": {
+			f:    TextLogFormat,
+			want: "time=\"2024-01-02T15:04:05Z\" level=warning msg=\"disk full\" a=1 b=\"two words\"\n",
+		},
+		"json lines": {
+			f:    JSONLinesLogFormat,
+			want: "{\"time\":\"2024-01-02T15:04:05Z\",\"level\":\"warning\",\"msg\":\"disk full\",\"a\":1,\"b\":\"two words\"}\n",
+		},
+		"compact": {
+			f:    CompactLogFormat,
+			want: "warning: disk full a=1 b=\"two words\"\n",
+		},
+		"unknown": {
+			f:    LogFormat(42),
+			want: "time=\"2024-01-02T15:04:05Z\" level=warning msg=\"disk full\" a=1 b=\"two words\"\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rb := getRecordBuffer()
+			defer rb.release()
+			rb.render(tt.f, output.Warning, timestamp, "disk full", fields)
+			if got := string(rb.b); got != tt.want {
+				t.Errorf("recordBuffer.render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// renderRecord returns a log record as recordBuffer.render renders it, without the newline
+func renderRecord(f LogFormat, l output.Level, timestamp time.Time, msg string, fields map[string]any) string {
+	rb := getRecordBuffer()
+	defer rb.release()
+	rb.render(f, l, timestamp, msg, fields)
+	return strings.TrimSuffix(string(rb.b), "\n")
+}
+
+func Test_recordBuffer_release(t *testing.T) {
+	rb := getRecordBuffer()
+	rb.b = append(rb.b, "leftover"...)
+	rb.sortedKeys(map[string]any{"k": 1})
+	rb.release()
+	if len(rb.b) != 0 || len(rb.keys) != 0 {
+		t.Errorf("recordBuffer.release() left b=%q keys=%v", rb.b, rb.keys)
+	}
+}
+
+func Test_simpleLogger_allocations(t *testing.T) {
+	if raceDetectorEnabled {
+		t.Skip("the race detector defeats buffer pooling")
+	}
+	fields := map[string]any{"command": "list", "count": 3, "verbose": true}
+	for name, f := range map[string]LogFormat{"text": TextLogFormat, "json lines": JSONLinesLogFormat} {
+		t.Run(name, func(t *testing.T) {
+			sl := parallelBenchmarkLogger(f)
+			if allocs := testing.AllocsPerRun(100, func() { sl.Info("listing", fields) }); allocs != 0 {
+				t.Errorf("simpleLogger.Info() allocations = %v, want 0", allocs)
+			}
+		})
+	}
+}
+
+func parallelBenchmarkLogger(f LogFormat, sinks ...LogSink) *simpleLogger {
+	sl := &simpleLogger{
+		writer:          io.Discard,
+		currentLogLevel: output.Info,
+		format:          f,
+		redaction:       DefaultRedactionRules,
+		lock:            &sync.RWMutex{},
+	}
+	for _, sink := range sinks {
+		sl.addSink(sink)
+	}
+	return sl
+}
+
+func BenchmarkSimpleLogger_parallel(b *testing.B) {
+	fields := map[string]any{"command": "list", "count": 3, "path": "C:/Users/me/Music"}
+	loggers := map[string]*simpleLogger{
+		"text":       parallelBenchmarkLogger(TextLogFormat),
+		"json lines": parallelBenchmarkLogger(JSONLinesLogFormat),
+		"text with a compact sink": parallelBenchmarkLogger(TextLogFormat,
+			LogSink{Writer: io.Discard, Level: output.Warning, Format: CompactLogFormat}),
+	}
+	for name, sl := range loggers {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					sl.Warning("listing", fields)
+				}
+			})
+		})
+	}
+	b.Run("filtered out", func(b *testing.B) {
+		sl := parallelBenchmarkLogger(TextLogFormat)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				sl.Debug("listing", fields)
+			}
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package cmd_toolkit
+
+import (
+	"context"
+	"maps"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file binds fields to loggers, so that code that always logs the same
+// fields, such as the command being run, need not repeat them, and makes such a logger
+// available, through a context.Context, to code that the command calls
+
+// FieldLogger is an output.Logger that can create child loggers, which add fields to every
+// message that they log
+type FieldLogger interface {
+	output.Logger
+	// With returns a child logger that adds the fields to every message that it logs, along
+	// with any fields bound to this logger; a field in a message takes precedence over a bound
+	// field with the same key, as does a field bound to a child over one bound to its parent
+	With(fields map[string]any) FieldLogger
+}
+
+// boundLogger is a FieldLogger that adds its fields to the messages that it passes to its
+// parent
+type boundLogger struct {
+	parent output.Logger
+	fields map[string]any
+}
+
+// LoggerWith returns a child of any output.Logger that adds the fields to every message
+// that it logs
+func LoggerWith(l output.Logger, fields map[string]any) FieldLogger {
+	if parent, ok := l.(FieldLogger); ok {
+		return parent.With(fields)
+	}
+	return &boundLogger{parent: l, fields: maps.Clone(fields)}
+}
+
+// With returns a child logger that adds the fields to every message that it logs
+func (sl *simpleLogger) With(fields map[string]any) FieldLogger {
+	return &boundLogger{parent: sl, fields: maps.Clone(fields)}
+}
+
+// With returns a child logger that adds the fields, as well as this logger's fields, to
+// every message that it logs
+func (bl *boundLogger) With(fields map[string]any) FieldLogger {
+	merged := maps.Clone(bl.fields)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	maps.Copy(merged, fields)
+	return &boundLogger{parent: bl.parent, fields: merged}
+}
+
+// WillLog returns true if the parent logger will log messages at a specified level; a
+// parent that cannot tell is assumed to log messages at every level
+func (bl *boundLogger) WillLog(l output.Level) bool {
+	if filter, ok := bl.parent.(levelFilter); ok {
+		return filter.WillLog(l)
+	}
+	return true
+}
+
+func (bl *boundLogger) merge(fields map[string]any) map[string]any {
+	if len(bl.fields) == 0 {
+		return fields
+	}
+	merged := maps.Clone(bl.fields)
+	maps.Copy(merged, fields)
+	return merged
+}
+
+// Trace outputs a trace log message
+func (bl *boundLogger) Trace(msg string, fields map[string]any) {
+	bl.parent.Trace(msg, bl.merge(fields))
+}
+
+// Debug outputs a debug log message
+func (bl *boundLogger) Debug(msg string, fields map[string]any) {
+	bl.parent.Debug(msg, bl.merge(fields))
+}
+
+// Info outputs an info log message
+func (bl *boundLogger) Info(msg string, fields map[string]any) {
+	bl.parent.Info(msg, bl.merge(fields))
+}
+
+// Warning outputs a warning log message
+func (bl *boundLogger) Warning(msg string, fields map[string]any) {
+	bl.parent.Warning(msg, bl.merge(fields))
+}
+
+// Error outputs an error log message
+func (bl *boundLogger) Error(msg string, fields map[string]any) {
+	bl.parent.Error(msg, bl.merge(fields))
+}
+
+// Panic outputs a panic log message and calls panic()
+func (bl *boundLogger) Panic(msg string, fields map[string]any) {
+	bl.parent.Panic(msg, bl.merge(fields))
+}
+
+// Fatal outputs a fatal log message and terminates the program
+func (bl *boundLogger) Fatal(msg string, fields map[string]any) {
+	bl.parent.Fatal(msg, bl.merge(fields))
+}
+
+// boundBus is an output.Bus that adds its fields to every message that it logs
+type boundBus struct {
+	output.Bus
+	fields map[string]any
+}
+
+// BusWith returns an output.Bus that behaves like the specified bus, except that it adds the
+// fields to every message that it logs; as with FieldLogger, a field in a message takes
+// precedence over a bound field with the same key, and calls may be nested
+func BusWith(o output.Bus, fields map[string]any) output.Bus {
+	if parent, ok := o.(*boundBus); ok {
+		merged := maps.Clone(parent.fields)
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		maps.Copy(merged, fields)
+		return &boundBus{Bus: parent.Bus, fields: merged}
+	}
+	return &boundBus{Bus: o, fields: maps.Clone(fields)}
+}
+
+// Log logs a message with the bus's fields
+func (bb *boundBus) Log(l output.Level, msg string, fields map[string]any) {
+	merged := maps.Clone(bb.fields)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	maps.Copy(merged, fields)
+	bb.Bus.Log(l, msg, merged)
+}
+
+type loggerContextKey struct{}
+
+// ContextWithLogger returns a copy of the context that carries the logger
+func ContextWithLogger(ctx context.Context, l FieldLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// LoggerFromContext returns the logger carried by the context, or, if the context carries
+// none, ProductionLogger
+func LoggerFromContext(ctx context.Context) FieldLogger {
+	if l, ok := ctx.Value(loggerContextKey{}).(FieldLogger); ok {
+		return l
+	}
+	return ProductionLogger
+}
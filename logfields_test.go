@@ -0,0 +1,103 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+func TestLoggerWith(t *testing.T) {
+	recorder := output.NewRecordingLogger()
+	command := cmdtoolkit.LoggerWith(recorder, map[string]any{"command": "list", "runID": 7})
+	file := command.With(map[string]any{"file": "a.txt", "runID": 8})
+	command.Info("starting", nil)
+	file.Warning("reading", map[string]any{"line": 3})
+	file.Error("overridden", map[string]any{"file": "b.txt"})
+	command.Trace("trace", map[string]any{"k": "v"})
+	command.Debug("debug", nil)
+	command.Panic("panic", nil)
+	command.Fatal("fatal", nil)
+	want := "" +
+		"level='info' command='list' runID='7' msg='starting'\n" +
+		"level='warning' command='list' file='a.txt' line='3' runID='8' msg='reading'\n" +
+		"level='error' command='list' file='b.txt' runID='8' msg='overridden'\n" +
+		"level='trace' command='list' k='v' runID='7' msg='trace'\n" +
+		"level='debug' command='list' runID='7' msg='debug'\n" +
+		"level='panic' command='list' runID='7' msg='panic'\n" +
+		"level='fatal' command='list' runID='7' msg='fatal'\n"
+	if got := recorder.String(); got != want {
+		t.Errorf("LoggerWith() logged %q, want %q", got, want)
+	}
+	filter, ok := command.(interface{ WillLog(output.Level) bool })
+	if !ok || !filter.WillLog(output.Trace) {
+		t.Errorf("LoggerWith() does not log at every level for a logger without a level")
+	}
+}
+
+func TestProductionLogger_With(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	buffer := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return buffer, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+	parent := cmdtoolkit.ProductionLogger.With(map[string]any{"command": "list"})
+	child := cmdtoolkit.LoggerWith(parent, map[string]any{"file": "a.txt"})
+	child.Info("reading", nil)
+	child.Debug("not logged", nil)
+	if got := buffer.String(); !strings.HasSuffix(got, "level=info msg=reading command=list file=a.txt\n") {
+		t.Errorf("With() logged %q", got)
+	}
+	filter := child.(interface{ WillLog(output.Level) bool })
+	if filter.WillLog(output.Debug) || !filter.WillLog(output.Info) {
+		t.Errorf("With() does not follow the production logger's level")
+	}
+}
+
+func TestBusWith(t *testing.T) {
+	o := output.NewRecorder()
+	bound := cmdtoolkit.BusWith(cmdtoolkit.BusWith(o, map[string]any{"command": "list"}), map[string]any{"runID": 7})
+	bound.Log(output.Info, "starting", nil)
+	bound.Log(output.Error, "failed", map[string]any{"runID": 8, "error": "oops"})
+	bound.ErrorPrintln("not logged")
+	o.Report(t, "BusWith()", output.WantedRecording{
+		Error: "not logged\n",
+		Log: "" +
+			"level='info' command='list' runID='7' msg='starting'\n" +
+			"level='error' command='list' error='oops' runID='8' msg='failed'\n",
+	})
+}
+
+func TestBusWith_nilFields(t *testing.T) {
+	o := output.NewRecorder()
+	bound := cmdtoolkit.BusWith(cmdtoolkit.BusWith(o, nil), map[string]any{"a": 1})
+	bound.Log(output.Info, "nested", nil)
+	cmdtoolkit.BusWith(o, nil).Log(output.Info, "unbound", nil)
+	o.Report(t, "BusWith()", output.WantedRecording{
+		Log: "" +
+			"level='info' a='1' msg='nested'\n" +
+			"level='info'  msg='unbound'\n",
+	})
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if got := cmdtoolkit.LoggerFromContext(context.Background()); got != cmdtoolkit.ProductionLogger {
+		t.Errorf("LoggerFromContext() = %v, want ProductionLogger", got)
+	}
+	recorder := output.NewRecordingLogger()
+	l := cmdtoolkit.LoggerWith(recorder, map[string]any{"command": "list"})
+	ctx := cmdtoolkit.ContextWithLogger(context.Background(), l)
+	cmdtoolkit.LoggerFromContext(ctx).With(map[string]any{"depth": 2}).Info("nested", nil)
+	if got, want := recorder.String(), "level='info' command='list' depth='2' msg='nested'\n"; got != want {
+		t.Errorf("LoggerFromContext() logged %q, want %q", got, want)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"io"
 	"io/fs"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
@@ -17,7 +16,6 @@ const (
 	logDirName       = "logs"
 	logFileExtension = ".log"
 	symlinkName      = "latest" + logFileExtension
-	maxLogFiles      = 10
 )
 
 var (
@@ -35,7 +33,9 @@ func initWriter(o output.Bus, applicationName string) (w io.Writer, path string)
 	}
 	path = findLogFilePath(o, applicationName)
 	if path != "" {
-		cleanup(o, path, applicationName)
+		cleanup(o, path, applicationName, ProductionLogger.retention)
+		// the writer opens the file for appending, and the logger writes each record with a
+		// single call, so that records from processes sharing the file are never interleaved
 		logWriter = cronowriter.MustNew(
 			filepath.Join(path, logFilePrefix(applicationName)+"%Y%m%d"+logFileExtension),
 			cronowriter.WithSymlink(filepath.Join(path, symlinkName)),
@@ -70,34 +70,44 @@ func findLogFilePath(o output.Bus, applicationName string) string {
 	return ""
 }
 
-func cleanup(o output.Bus, logPath, applicationName string) (found, deleted int) {
-	if files, dirRead := ReadDirectory(o, logPath); dirRead {
-		var fileMap = make(map[time.Time]fs.FileInfo)
-		times := make([]time.Time, 0, len(files))
-		for _, file := range files {
-			if isLogFile(file, applicationName) {
-				modificationTime := file.ModTime()
-				fileMap[modificationTime] = file
-				times = append(times, modificationTime)
-			}
+// cleanup compresses and deletes old log files, as the retention policy dictates; it is
+// skipped if another process sharing the log directory is already cleaning it up, and it
+// never deletes the newest log file, which processes may be writing to
+func cleanup(o output.Bus, logPath, applicationName string, policy RetentionPolicy) (found, deleted int) {
+	release, busy := lockCleanup(logPath, applicationName)
+	if busy {
+		// nothing is logged, as cleanup runs before the log file writer is installed
+		return
+	}
+	defer release()
+	files, dirRead := ReadDirectory(o, logPath)
+	if !dirRead {
+		return
+	}
+	now := time.Now()
+	if compressOldLogFiles(o, logPath, applicationName, files, now) > 0 {
+		if files, dirRead = ReadDirectory(o, logPath); !dirRead {
+			return
+		}
+	}
+	logFiles := make([]logFile, 0, len(files))
+	for _, file := range files {
+		if isLogFile(file, applicationName) {
+			logFiles = append(logFiles, newLogFile(file, logFilePrefix(applicationName)))
 		}
-		found = len(times)
-		if found > maxLogFiles && len(times) > 0 {
-			sort.Slice(times, func(i, j int) bool {
-				return times[i].Before(times[j])
-			})
-			limit := len(times) - maxLogFiles
-			for k := range limit {
-				entry := fileMap[times[k]]
-				if entry != nil {
-					logFile := filepath.Join(logPath, entry.Name())
-					if deleteLogFile(o, logFile) {
-						deleted++
-					}
-				}
-			}
+	}
+	found = len(logFiles)
+	sortLogFiles(logFiles)
+	count := policy.expired(logFiles, now)
+	if count == len(logFiles) && count > 0 {
+		count--
+	}
+	for _, expired := range logFiles[:count] {
+		if deleteLogFile(o, filepath.Join(logPath, expired.name)) {
+			deleted++
 		}
 	}
+	removeDanglingSymlink(filepath.Join(logPath, symlinkName))
 	return
 }
 
@@ -115,10 +125,13 @@ func isLogFile(file fs.FileInfo, applicationName string) (ok bool) {
 		ok = strings.HasPrefix(
 			fileName,
 			logFilePrefix(applicationName),
-		) && strings.HasSuffix(
+		) && (strings.HasSuffix(
 			fileName,
 			logFileExtension,
-		)
+		) || strings.HasSuffix(
+			fileName,
+			compressedLogFileExtension,
+		))
 	}
 	return
 }
@@ -5,12 +5,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/majohn-r/output"
 	"github.com/spf13/afero"
+	"github.com/utahta/go-cronowriter"
 )
 
 func Test_initWriter(t *testing.T) {
@@ -123,7 +126,7 @@ func Test_cleanup(t *testing.T) {
 			preTest: func() {
 				_ = fileSystem.Mkdir("maxLogFiles", StdDirPermissions)
 				prefix := logFilePrefix("")
-				for k := range maxLogFiles {
+				for k := range DefaultRetentionPolicy.MaxFiles {
 					fileName := fmt.Sprintf("%s%d%s", prefix, k, logFileExtension)
 					_ = afero.WriteFile(
 						fileSystem,
@@ -135,13 +138,13 @@ func Test_cleanup(t *testing.T) {
 			},
 			postTest:  func(_ *testing.T) {},
 			path:      "maxLogFiles",
-			wantFound: maxLogFiles,
+			wantFound: DefaultRetentionPolicy.MaxFiles,
 		},
 		"lots of files present": {
 			preTest: func() {
 				_ = fileSystem.Mkdir("manyLogFiles", StdDirPermissions)
 				prefix := logFilePrefix("")
-				for k := range maxLogFiles + 1 {
+				for k := range DefaultRetentionPolicy.MaxFiles + 1 {
 					fileName := fmt.Sprintf("%s%d%s", prefix, k, logFileExtension)
 					_ = afero.WriteFile(
 						fileSystem,
@@ -164,7 +167,7 @@ func Test_cleanup(t *testing.T) {
 				}
 			},
 			path:        "manyLogFiles",
-			wantFound:   maxLogFiles + 1,
+			wantFound:   DefaultRetentionPolicy.MaxFiles + 1,
 			wantDeleted: 1,
 		},
 	}
@@ -173,7 +176,7 @@ func Test_cleanup(t *testing.T) {
 			tt.preTest()
 			defer tt.postTest(t)
 			o := output.NewRecorder()
-			gotFound, gotDeleted := cleanup(o, tt.path, "")
+			gotFound, gotDeleted := cleanup(o, tt.path, "", DefaultRetentionPolicy)
 			if gotFound != tt.wantFound {
 				t.Errorf("cleanup() found %d want %d", gotFound, tt.wantFound)
 			}
@@ -282,6 +285,19 @@ func Test_isLogFile(t *testing.T) {
 			},
 			wantOk: true,
 		},
+		"well named compressed file": {
+			file: fi{
+				name: fmt.Sprintf("%sxx%s", logFilePrefix(""), compressedLogFileExtension),
+				mode: 0,
+			},
+			wantOk: true,
+		},
+		"compressed file of another kind": {
+			file: fi{
+				name: fmt.Sprintf("%sxx.txt.gz", logFilePrefix("")),
+				mode: 0,
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -385,3 +401,46 @@ func Test_findLogFilePath(t *testing.T) {
 		})
 	}
 }
+
+func Test_sharedLogFile(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, logFilePrefix("app")+"%Y%m%d"+logFileExtension)
+	// each logger has a file handle of its own, as each process sharing the file would
+	const loggers, goroutines, records = 2, 4, 200
+	message := strings.Repeat("x", 4000)
+	var wg sync.WaitGroup
+	for range loggers {
+		writer := cronowriter.MustNew(pattern)
+		defer func() {
+			_ = writer.Close()
+		}()
+		sl := &simpleLogger{writer: writer, currentLogLevel: output.Info, lock: &sync.RWMutex{}}
+		for g := range goroutines {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range records {
+					sl.Info(message, map[string]any{"goroutine": g, "record": r})
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+logFileExtension))
+	lines := 0
+	for _, file := range files {
+		content, _ := os.ReadFile(file)
+		for _, line := range strings.SplitAfter(string(content), "\n") {
+			if line == "" {
+				continue
+			}
+			lines++
+			if record, parseErr := ParseLogRecord(line); parseErr != nil || record.Message != message {
+				t.Fatalf("shared log file has a damaged record %.80q: %v", line, parseErr)
+			}
+		}
+	}
+	if want := loggers * goroutines * records; lines != want {
+		t.Errorf("shared log file has %d records, want %d", lines, want)
+	}
+}
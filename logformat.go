@@ -0,0 +1,55 @@
+package cmd_toolkit
+
+import "fmt"
+
+// LogFormat specifies the format of the records written to the log
+type LogFormat int32
+
+const (
+	// TextLogFormat writes each record as a line of key=value pairs, e.g.,
+	// time="2024-01-02T15:04:05Z" level=info msg=hello field1=45; this is the default format
+	TextLogFormat LogFormat = iota
+	// JSONLinesLogFormat writes each record as a JSON object on a line of its own, e.g.,
+	// {"time":"2024-01-02T15:04:05Z","level":"info","msg":"hello","field1":45}; field values
+	// keep their types, except that errors are written as their messages
+	JSONLinesLogFormat
+	// CompactLogFormat writes each record as a line holding the level, the message, and the
+	// fields, without the time, e.g., warning: hello field1=45; control characters in the
+	// message are escaped. It suits sinks, such as stderr, that a person reads as the program
+	// runs
+	CompactLogFormat
+)
+
+// LogOption customizes the logger initialized by InitLogging and InitLoggingWithLevel
+type LogOption func(*simpleLogger)
+
+// WithLogFormat selects the format of the records written to the log
+func WithLogFormat(f LogFormat) LogOption {
+	return func(sl *simpleLogger) {
+		sl.format = f
+	}
+}
+
+// jsonCompatible converts errors, which would otherwise be encoded as empty objects, into
+// their messages, including those nested in maps and slices
+func jsonCompatible(v any) any {
+	switch value := v.(type) {
+	case error:
+		// fmt copes with nil receivers, which calling Error() directly might not
+		return fmt.Sprint(value)
+	case map[string]any:
+		converted := make(map[string]any, len(value))
+		for k, nested := range value {
+			converted[k] = jsonCompatible(nested)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(value))
+		for k, nested := range value {
+			converted[k] = jsonCompatible(nested)
+		}
+		return converted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,95 @@
+package cmd_toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+type nilPointerError struct{}
+
+func (*nilPointerError) Error() string { return "nil pointer error" }
+
+func Test_recordBuffer_appendJSONRecord(t *testing.T) {
+	timestamp := time.Unix(0, 0).UTC()
+	var nilErr *nilPointerError
+	tests := map[string]struct {
+		l      output.Level
+		msg    string
+		fields map[string]any
+		want   string
+	}{
+		"no fields": {
+			l:    output.Info,
+			msg:  "",
+			want: `{"time":"1970-01-01T00:00:00Z","level":"info","msg":""}`,
+		},
+		"typed fields": {
+			l:   output.Warning,
+			msg: "hello \"fence\" post",
+			fields: map[string]any{
+				"count":   45,
+				"ratio":   0.5,
+				"ok":      true,
+				"names":   []string{"a", "b c"},
+				"error":   errors.New("file not found"),
+				"nothing": nil,
+			},
+			want: `{"time":"1970-01-01T00:00:00Z","level":"warning","msg":"hello \"fence\" post",` +
+				`"count":45,"error":"file not found","names":["a","b c"],"nothing":null,"ok":true,"ratio":0.5}`,
+		},
+		"nested fields": {
+			l:   output.Error,
+			msg: "nested",
+			fields: map[string]any{
+				"outer": map[string]any{
+					"inner": map[string]any{"error": errors.New("deep")},
+					"list":  []any{1, errors.New("in a list")},
+				},
+				"typed nil error": nilErr,
+			},
+			want: `{"time":"1970-01-01T00:00:00Z","level":"error","msg":"nested",` +
+				`"outer":{"inner":{"error":"deep"},"list":[1,"in a list"]},"typed nil error":"nil pointer error"}`,
+		},
+		"unencodable field": {
+			l:      output.Debug,
+			msg:    "channel",
+			fields: map[string]any{"c": make(chan int)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := renderRecord(JSONLinesLogFormat, tt.l, timestamp, tt.msg, tt.fields)
+			if !json.Valid([]byte(got)) {
+				t.Errorf("render() = %q is not valid JSON", got)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_simpleLogger_doLog_jsonLines(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	sl := &simpleLogger{
+		writer:          buffer,
+		currentLogLevel: output.Info,
+		format:          JSONLinesLogFormat,
+		lock:            &sync.RWMutex{},
+	}
+	timestamp := time.Unix(0, 0).UTC()
+	sl.doLog(output.Info, timestamp, "first", map[string]any{"n": 1})
+	sl.doLog(output.Info, timestamp, "second", nil)
+	want := "" +
+		`{"time":"1970-01-01T00:00:00Z","level":"info","msg":"first","n":1}` + "\n" +
+		`{"time":"1970-01-01T00:00:00Z","level":"info","msg":"second"}` + "\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("simpleLogger.doLog() = %q, want %q", got, want)
+	}
+}
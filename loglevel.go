@@ -0,0 +1,143 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file controls the logger's level at runtime: parsing a level from the
+// command line, an environment variable, or the configuration file; changing the level
+// safely while other goroutines are logging; and switching to trace level while a file exists
+
+// LogLevelFlagName is the name of the flag provided by LogLevelFlag
+const LogLevelFlagName = "log-level"
+
+// logLevelNames lists the level names, from most to least severe
+var logLevelNames = []string{"fatal", "panic", "error", "warning", "info", "debug", "trace"}
+
+// ParseLogLevel converts a level name, such as "debug", into an output.Level; case is
+// ignored
+func ParseLogLevel(s string) (output.Level, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for l, levelName := range levelsToString {
+		if levelName == name {
+			return l, nil
+		}
+	}
+	return defaultLoggingLevel, fmt.Errorf("%q is not a log level; use one of %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// LogLevelFlag returns the details of a --log-level flag, for inclusion in a FlagSet under
+// LogLevelFlagName; its default value is "info", and, like any flag, its default value may be
+// set in the configuration file. If envVar is not empty, it names an environment variable
+// that may also set the level. Use LogLevelFromFlags to read the level
+func LogLevelFlag(envVar string) *FlagDetails {
+	return &FlagDetails{
+		Usage:        "level of detail to log: " + strings.Join(logLevelNames, ", "),
+		ExpectedType: StringType,
+		DefaultValue: levelsToString[defaultLoggingLevel],
+		Completion:   CompletionHints{Values: logLevelNames},
+		Rules: ValidationRules{
+			Custom: func(value any) error {
+				_, parseErr := ParseLogLevel(fmt.Sprint(value))
+				return parseErr
+			},
+		},
+		EnvVar: envVar,
+	}
+}
+
+// LogLevelFromFlags returns the level set by the flag provided by LogLevelFlag
+func LogLevelFromFlags(o output.Bus, results map[string]*CommandFlag[any]) (output.Level, error) {
+	flag, flagErr := GetFlag[string](o, results, LogLevelFlagName)
+	if flagErr != nil {
+		return defaultLoggingLevel, flagErr
+	}
+	l, parseErr := ParseLogLevel(flag.Value)
+	if parseErr != nil {
+		reportFlagValueError(o, &FlagValueError{Flag: LogLevelFlagName, Value: flag.Value, Err: parseErr})
+	}
+	return l, parseErr
+}
+
+// SetLevel changes the level of the messages that the logger writes to the log file; it is
+// safe to call while other goroutines are logging
+func (sl *simpleLogger) SetLevel(l output.Level) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	sl.currentLogLevel = l
+}
+
+// Level returns the level of the messages that the logger writes to the log file
+func (sl *simpleLogger) Level() output.Level {
+	sl.lock.RLock()
+	defer sl.lock.RUnlock()
+	return sl.currentLogLevel
+}
+
+// traceFilePollInterval is how often TraceWhileFileExists checks for its file
+const traceFilePollInterval = time.Second
+
+// TraceWhileFileExists switches the logger to trace level while the specified file exists,
+// and back to the level it had before once the file is removed; e.g.,
+//
+//	stop := cmd_toolkit.ProductionLogger.TraceWhileFileExists(filepath.Join(cmd_toolkit.LogPath(), "trace"))
+//	defer stop()
+//
+// lets a user turn tracing on in a running program by creating a file named trace in the log
+// directory. A file is used, rather than a signal, because the only signal that a Windows
+// program can catch is the interrupt sent by Ctrl-C, which the program must stay free to
+// act on. The file is checked every traceFilePollInterval; calling the returned function
+// stops watching for it, leaving the level as it is
+func (sl *simpleLogger) TraceWhileFileExists(path string) (stop func()) {
+	ticker := time.NewTicker(traceFilePollInterval)
+	quit := make(chan struct{})
+	done := sl.watchTraceFile(path, ticker.C, quit)
+	return func() {
+		ticker.Stop()
+		close(quit)
+		<-done
+	}
+}
+
+// watchTraceFile checks for the file each time a tick arrives, switching to trace level when
+// it appears and back when it disappears, until quit is closed; the returned channel is
+// closed when it stops
+func (sl *simpleLogger) watchTraceFile(path string, ticks <-chan time.Time, quit <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracing := false
+		var restored output.Level
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticks:
+			}
+			_, statErr := fileSystem.Stat(path)
+			if exists := statErr == nil; exists == tracing {
+				continue
+			}
+			tracing = !tracing
+			sl.lock.Lock()
+			from := sl.currentLogLevel
+			to := restored
+			if tracing {
+				restored = from
+				to = output.Trace
+			}
+			sl.currentLogLevel = to
+			sl.lock.Unlock()
+			sl.log(output.Info, "log level changed", map[string]any{
+				"file": path,
+				"from": levelsToString[from],
+				"to":   levelsToString[to],
+			})
+		}
+	}()
+	return done
+}
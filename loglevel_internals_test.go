@@ -0,0 +1,93 @@
+package cmd_toolkit
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func Test_simpleLogger_SetLevel(t *testing.T) {
+	sl := &simpleLogger{
+		writer:          &bytes.Buffer{},
+		currentLogLevel: output.Info,
+		lock:            &sync.RWMutex{},
+	}
+	var wg sync.WaitGroup
+	for k := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				sl.SetLevel(output.Level(k))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				sl.Info("message", nil)
+			}
+		}()
+	}
+	wg.Wait()
+	sl.SetLevel(output.Debug)
+	if got := sl.Level(); got != output.Debug {
+		t.Errorf("Level() = %v, want %v", got, output.Debug)
+	}
+}
+
+func Test_simpleLogger_watchTraceFile(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	fileSystem = afero.NewMemMapFs()
+	buffer := &bytes.Buffer{}
+	sl := &simpleLogger{
+		writer:          buffer,
+		currentLogLevel: output.Debug,
+		lock:            &sync.RWMutex{},
+	}
+	ticks := make(chan time.Time)
+	quit := make(chan struct{})
+	done := sl.watchTraceFile("trace", ticks, quit)
+	// the ticks are unbuffered, so each is received only after the previous one is handled
+	ticks <- time.Now()
+	_ = afero.WriteFile(fileSystem, "trace", nil, StdFilePermissions)
+	ticks <- time.Now()
+	ticks <- time.Now()
+	if got := sl.Level(); got != output.Trace {
+		t.Errorf("Level() = %v while the file exists, want %v", got, output.Trace)
+	}
+	_ = fileSystem.Remove("trace")
+	ticks <- time.Now()
+	ticks <- time.Now()
+	close(quit)
+	<-done
+	if got := sl.Level(); got != output.Debug {
+		t.Errorf("Level() = %v after the file is removed, want %v", got, output.Debug)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 ||
+		!strings.HasSuffix(lines[0], `level=info msg="log level changed" file=trace from=debug to=trace`) ||
+		!strings.HasSuffix(lines[1], `level=info msg="log level changed" file=trace from=trace to=debug`) {
+		t.Errorf("watchTraceFile() logged %q", buffer.String())
+	}
+}
+
+func Test_simpleLogger_TraceWhileFileExists(t *testing.T) {
+	sl := &simpleLogger{
+		writer:          &bytes.Buffer{},
+		currentLogLevel: output.Info,
+		lock:            &sync.RWMutex{},
+	}
+	stop := sl.TraceWhileFileExists("no such file")
+	stop()
+	if got := sl.Level(); got != output.Info {
+		t.Errorf("Level() = %v, want %v", got, output.Info)
+	}
+}
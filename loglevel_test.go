@@ -0,0 +1,146 @@
+package cmd_toolkit_test
+
+import (
+	"testing"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/pflag"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]struct {
+		s       string
+		want    output.Level
+		wantErr string
+	}{
+		"trace":      {s: "trace", want: output.Trace},
+		"debug":      {s: "debug", want: output.Debug},
+		"info":       {s: "info", want: output.Info},
+		"warning":    {s: "warning", want: output.Warning},
+		"error":      {s: "error", want: output.Error},
+		"panic":      {s: "panic", want: output.Panic},
+		"fatal":      {s: "fatal", want: output.Fatal},
+		"mixed case": {s: " Debug ", want: output.Debug},
+		"unknown name": {
+			s:       "loud",
+			want:    output.Info,
+			wantErr: `"loud" is not a log level; use one of fatal, panic, error, warning, info, debug, trace`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := cmdtoolkit.ParseLogLevel(tt.s)
+			if got != tt.want {
+				t.Errorf("ParseLogLevel() = %v, want %v", got, tt.want)
+			}
+			if gotErr == nil && tt.wantErr != "" || gotErr != nil && gotErr.Error() != tt.wantErr {
+				t.Errorf("ParseLogLevel() error = %v, want %q", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogLevelFlag(t *testing.T) {
+	set := &cmdtoolkit.FlagSet{
+		Name: "app",
+		Details: map[string]*cmdtoolkit.FlagDetails{
+			cmdtoolkit.LogLevelFlagName: cmdtoolkit.LogLevelFlag("CMD_TOOLKIT_TEST_LOG_LEVEL"),
+		},
+	}
+	tests := map[string]struct {
+		configured *cmdtoolkit.Configuration
+		env        string
+		args       []string
+		want       output.Level
+		wantErr    bool
+		output.WantedRecording
+	}{
+		"default": {
+			configured: cmdtoolkit.EmptyConfiguration(),
+			want:       output.Info,
+		},
+		"configuration": {
+			configured: &cmdtoolkit.Configuration{
+				ConfigurationMap: map[string]*cmdtoolkit.Configuration{
+					"app": {StringMap: map[string]string{cmdtoolkit.LogLevelFlagName: "warning"}},
+				},
+			},
+			want: output.Warning,
+		},
+		"environment": {
+			configured: cmdtoolkit.EmptyConfiguration(),
+			env:        "error",
+			want:       output.Error,
+		},
+		"command line": {
+			configured: cmdtoolkit.EmptyConfiguration(),
+			env:        "error",
+			args:       []string{"--log-level=TRACE"},
+			want:       output.Trace,
+		},
+		"invalid command line value": {
+			configured: cmdtoolkit.EmptyConfiguration(),
+			args:       []string{"--log-level=loud"},
+			want:       output.Info,
+			wantErr:    true,
+			WantedRecording: output.WantedRecording{
+				Error: "The value \"loud\" for flag --log-level cannot be used: \"loud\" is not a log level;" +
+					" use one of fatal, panic, error, warning, info, debug, trace.\n",
+				Log: "level='error'" +
+					" error='\"loud\" is not a log level; use one of fatal, panic, error, warning, info, debug, trace'" +
+					" flag='log-level'" +
+					" value='loud'" +
+					" msg='invalid flag value'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("CMD_TOOLKIT_TEST_LOG_LEVEL", tt.env)
+			}
+			o := output.NewRecorder()
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			cmdtoolkit.AddFlags(o, tt.configured, flags, set)
+			if parseErr := flags.Parse(tt.args); parseErr != nil {
+				t.Fatalf("Parse() failed: %v", parseErr)
+			}
+			results, errs := cmdtoolkit.ReadFlags(flags, set)
+			if !cmdtoolkit.ProcessFlagErrors(o, errs) {
+				if !tt.wantErr {
+					t.Errorf("ReadFlags() errors = %v", errs)
+				}
+			} else {
+				got, gotErr := cmdtoolkit.LogLevelFromFlags(o, results)
+				if got != tt.want {
+					t.Errorf("LogLevelFromFlags() = %v, want %v", got, tt.want)
+				}
+				if (gotErr != nil) != tt.wantErr {
+					t.Errorf("LogLevelFromFlags() error = %v, wantErr %t", gotErr, tt.wantErr)
+				}
+			}
+			o.Report(t, "LogLevelFlag()", tt.WantedRecording)
+		})
+	}
+}
+
+func TestLogLevelFromFlags(t *testing.T) {
+	o := output.NewRecorder()
+	results := map[string]*cmdtoolkit.CommandFlag[any]{
+		cmdtoolkit.LogLevelFlagName: {Value: "loud"},
+	}
+	got, gotErr := cmdtoolkit.LogLevelFromFlags(o, results)
+	if got != output.Info || gotErr == nil {
+		t.Errorf("LogLevelFromFlags() = %v, %v", got, gotErr)
+	}
+	o.Report(t, "LogLevelFromFlags()", output.WantedRecording{
+		Error: "The value \"loud\" for flag --log-level cannot be used: \"loud\" is not a log level;" +
+			" use one of fatal, panic, error, warning, info, debug, trace.\n",
+		Log: "level='error'" +
+			" error='\"loud\" is not a log level; use one of fatal, panic, error, warning, info, debug, trace'" +
+			" flag='log-level'" +
+			" value='loud'" +
+			" msg='invalid flag value'\n",
+	})
+}
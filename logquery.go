@@ -0,0 +1,353 @@
+package cmd_toolkit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file reads the log back: it parses the records written in the text and
+// JSON Lines formats, and finds the records that match a query in all the log files in
+// LogPath(), including the compressed files of past days
+
+// maxLogLineLength is the length of the longest log record that can be read
+const maxLogLineLength = 1024 * 1024
+
+// logFollowInterval is how often FollowLogs looks for new records
+var logFollowInterval = time.Second
+
+// LogRecord is a record read from the log
+type LogRecord struct {
+	Time    time.Time
+	Level   output.Level
+	Message string
+	// Fields holds the record's fields; the values of fields read from the text format are
+	// strings, and those read from the JSON Lines format have their JSON types, with numbers
+	// as json.Number values
+	Fields map[string]any
+}
+
+// ParseLogRecord parses a line written to the log in the text or JSON Lines format
+func ParseLogRecord(line string) (LogRecord, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "{") {
+		return parseJSONRecord(line)
+	}
+	return parseTextRecord(line)
+}
+
+func parseTextRecord(line string) (LogRecord, error) {
+	record := LogRecord{Fields: map[string]any{}}
+	var header []string
+	rest := line
+	for rest != "" {
+		separator := strings.IndexByte(rest, '=')
+		if separator <= 0 {
+			return LogRecord{}, fmt.Errorf("malformed field %q", rest)
+		}
+		key := rest[:separator]
+		rest = rest[separator+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, quoteErr := strconv.QuotedPrefix(rest)
+			if quoteErr != nil {
+				return LogRecord{}, fmt.Errorf("malformed value for %q: %w", key, quoteErr)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		rest = strings.TrimPrefix(rest, " ")
+		if len(header) < len(logRecordHeader) {
+			if key != logRecordHeader[len(header)] {
+				return LogRecord{}, fmt.Errorf("found %q in place of %q", key, logRecordHeader[len(header)])
+			}
+			header = append(header, key)
+			if setErr := record.setHeader(key, value); setErr != nil {
+				return LogRecord{}, setErr
+			}
+			continue
+		}
+		record.Fields[key] = value
+	}
+	if len(header) < len(logRecordHeader) {
+		return LogRecord{}, fmt.Errorf("missing %s", logRecordHeader[len(header)])
+	}
+	return record, nil
+}
+
+// logRecordHeader lists the keys of the values that begin every record, in order
+var logRecordHeader = []string{"time", "level", "msg"}
+
+// setHeader sets one of the values that begin every record
+func (lr *LogRecord) setHeader(key, value string) error {
+	switch key {
+	case "time":
+		t, parseErr := time.Parse(time.RFC3339, value)
+		if parseErr != nil {
+			return fmt.Errorf("malformed time %q", value)
+		}
+		lr.Time = t
+	case "level":
+		l, parseErr := ParseLogLevel(value)
+		if parseErr != nil {
+			return parseErr
+		}
+		lr.Level = l
+	case "msg":
+		lr.Message = value
+	}
+	return nil
+}
+
+func parseJSONRecord(line string) (LogRecord, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var values map[string]any
+	if decodeErr := decoder.Decode(&values); decodeErr != nil {
+		return LogRecord{}, decodeErr
+	}
+	record := LogRecord{Fields: values}
+	for _, key := range logRecordHeader {
+		value, isString := values[key].(string)
+		if !isString {
+			return LogRecord{}, fmt.Errorf("missing %s", key)
+		}
+		if setErr := record.setHeader(key, value); setErr != nil {
+			return LogRecord{}, setErr
+		}
+		delete(values, key)
+	}
+	return record, nil
+}
+
+// LogQuery selects log records; the zero value selects every record
+type LogQuery struct {
+	// Levels, if not empty, are the levels of the records to select; LogLevelsAtLeast
+	// provides the levels at least as severe as a specified level
+	Levels []output.Level
+	// Since, if not zero, excludes records logged before it
+	Since time.Time
+	// Until, if not zero, excludes records logged after it
+	Until time.Time
+	// Message, if not nil, must match the record's message
+	Message *regexp.Regexp
+	// Fields maps field keys to the values, as rendered by fmt.Sprint, that the record's
+	// fields must have
+	Fields map[string]string
+}
+
+// LogLevelsAtLeast returns the levels at least as severe as the specified level
+func LogLevelsAtLeast(l output.Level) []output.Level {
+	var levels []output.Level
+	for level := output.Fatal; level <= l && level <= output.Trace; level++ {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// Matches returns true if the query selects the record
+func (q LogQuery) Matches(r LogRecord) bool {
+	switch {
+	case len(q.Levels) > 0 && !containsLevel(q.Levels, r.Level):
+		return false
+	case !q.Since.IsZero() && r.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && r.Time.After(q.Until):
+		return false
+	case q.Message != nil && !q.Message.MatchString(r.Message):
+		return false
+	}
+	for key, want := range q.Fields {
+		value, found := r.Fields[key]
+		if !found || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func containsLevel(levels []output.Level, l output.Level) bool {
+	for _, level := range levels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryLogs returns the records, in the order in which they were logged, that the query
+// selects from the application's log files in LogPath(); lines that cannot be parsed are
+// skipped. Problems reading the files are reported, and the returned bool is false if there
+// were any
+func QueryLogs(o output.Bus, applicationName string, q LogQuery) ([]LogRecord, bool) {
+	var records []LogRecord
+	_, _, ok := scanLogFiles(o, applicationName, q, func(r LogRecord) {
+		records = append(records, r)
+	})
+	return records, ok
+}
+
+// FollowLogs passes the records that the query selects to the handler, first from the
+// application's log files, as QueryLogs does, and then as they are logged, until the context
+// is done; it returns false if there were problems reading the files
+func FollowLogs(ctx context.Context, o output.Bus, applicationName string, q LogQuery, handle func(LogRecord)) bool {
+	current, offset, ok := scanLogFiles(o, applicationName, q, handle)
+	if !ok {
+		return false
+	}
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-ticker.C:
+		}
+		newest := newestLogFile(applicationName)
+		if newest != current && current != "" {
+			// finish the file that was being followed before moving to its successor
+			_, _ = readLogRecords(filepath.Join(LogPath(), current), offset, q, handle)
+		}
+		if newest != current {
+			current, offset = newest, 0
+		}
+		if current == "" {
+			continue
+		}
+		if next, readErr := readLogRecords(filepath.Join(LogPath(), current), offset, q, handle); readErr == nil {
+			offset = next
+		}
+	}
+}
+
+// scanLogFiles passes the selected records in the application's log files to the handler,
+// returning the name of the newest uncompressed log file, if any, and the offset of the end
+// of the last complete record in it
+func scanLogFiles(o output.Bus, applicationName string, q LogQuery, handle func(LogRecord)) (string, int64, bool) {
+	dir := LogPath()
+	if dir == "" {
+		o.ErrorPrintln("The log cannot be read, as logging has not been initialized.")
+		return "", 0, false
+	}
+	files, dirRead := ReadDirectory(o, dir)
+	if !dirRead {
+		return "", 0, false
+	}
+	logFiles := collectLogFiles(files, applicationName)
+	ok := true
+	var newest string
+	var offset int64
+	for _, lf := range logFiles {
+		fileName := filepath.Join(dir, lf.name)
+		end, readErr := readLogRecords(fileName, 0, q, handle)
+		if readErr != nil {
+			o.ErrorPrintf("The log file %q cannot be read: %s.\n", fileName, ErrorToString(readErr))
+			ok = false
+			continue
+		}
+		if !lf.compressed {
+			newest, offset = lf.name, end
+		}
+	}
+	return newest, offset, ok
+}
+
+func collectLogFiles(files []fs.FileInfo, applicationName string) []logFile {
+	logFiles := make([]logFile, 0, len(files))
+	for _, file := range files {
+		if isLogFile(file, applicationName) {
+			logFiles = append(logFiles, newLogFile(file, logFilePrefix(applicationName)))
+		}
+	}
+	sortLogFiles(logFiles)
+	return logFiles
+}
+
+// newestLogFile returns the name of the newest uncompressed log file, or an empty string
+func newestLogFile(applicationName string) string {
+	files, dirRead := ReadDirectory(output.NewNilBus(), LogPath())
+	if !dirRead {
+		return ""
+	}
+	logFiles := collectLogFiles(files, applicationName)
+	for k := len(logFiles) - 1; k >= 0; k-- {
+		if !logFiles[k].compressed {
+			return logFiles[k].name
+		}
+	}
+	return ""
+}
+
+// readLogRecords passes the selected records in a log file, starting at the offset, to the
+// handler, returning the offset of the end of the last complete record; a compressed file is
+// always read from its beginning
+func readLogRecords(fileName string, offset int64, q LogQuery, handle func(LogRecord)) (int64, error) {
+	f, openErr := fileSystem.Open(fileName)
+	if openErr != nil {
+		return offset, openErr
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var r io.Reader = f
+	if strings.HasSuffix(fileName, compressedLogFileExtension) {
+		gz, gzErr := gzip.NewReader(f)
+		if gzErr != nil {
+			return offset, gzErr
+		}
+		r = gz
+	} else if _, seekErr := f.Seek(offset, io.SeekStart); seekErr != nil {
+		return offset, seekErr
+	}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, length, readErr := readLogLine(reader)
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				// an incomplete line is still being written, and is read next time
+				return offset, nil
+			}
+			return offset, readErr
+		}
+		offset += int64(length)
+		if record, parseErr := ParseLogRecord(string(line)); parseErr == nil && q.Matches(record) {
+			handle(record)
+		}
+	}
+}
+
+// readLogLine reads a line, returning its beginning, up to maxLogLineLength bytes, and its
+// full length, including its newline
+func readLogLine(reader *bufio.Reader) ([]byte, int, error) {
+	var line []byte
+	length := 0
+	for {
+		chunk, readErr := reader.ReadSlice('\n')
+		length += len(chunk)
+		if room := maxLogLineLength - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		if !errors.Is(readErr, bufio.ErrBufferFull) {
+			return line, length, readErr
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package cmd_toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func TestParseLogRecord(t *testing.T) {
+	timestamp := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+	fields := map[string]any{
+		"count":   45,
+		"ok":      true,
+		"names":   []string{"a", "b c"},
+		"field 9": "x=y",
+		"empty":   "",
+		"msg":     "a field named msg",
+	}
+	tests := map[string]struct {
+		line    string
+		want    LogRecord
+		wantErr bool
+	}{
+		"text": {
+			line: formatTextRecord(output.Warning, timestamp, "hello \"fence\" post", fields) + "\n",
+			want: LogRecord{
+				Time:    timestamp,
+				Level:   output.Warning,
+				Message: "hello \"fence\" post",
+				Fields: map[string]any{
+					"count":   "45",
+					"ok":      "true",
+					"names":   "[a b c]",
+					"field 9": "x=y",
+					"empty":   "",
+					"msg":     "a field named msg",
+				},
+			},
+		},
+		"text without fields": {
+			line: formatTextRecord(output.Trace, timestamp, "", nil),
+			want: LogRecord{Time: timestamp, Level: output.Trace, Fields: map[string]any{}},
+		},
+		"json": {
+			line: renderRecord(JSONLinesLogFormat, output.Error, timestamp, "failed", map[string]any{"count": 45, "ok": true}),
+			want: LogRecord{
+				Time:    timestamp,
+				Level:   output.Error,
+				Message: "failed",
+				Fields:  map[string]any{"count": json.Number("45"), "ok": true},
+			},
+		},
+		"empty line":       {line: "", wantErr: true},
+		"no separator":     {line: "garbage", wantErr: true},
+		"bad time":         {line: `time=yesterday level=info msg=hi`, wantErr: true},
+		"bad level":        {line: `time="2024-01-02T15:04:05Z" level=loud msg=hi`, wantErr: true},
+		"missing message":  {line: `time="2024-01-02T15:04:05Z" level=info`, wantErr: true},
+		"wrong order":      {line: `level=info time="2024-01-02T15:04:05Z" msg=hi`, wantErr: true},
+		"unterminated":     {line: `time="2024-01-02T15:04:05Z level=info msg=hi`, wantErr: true},
+		"compact format":   {line: "warning: disk nearly full", wantErr: true},
+		"bad json":         {line: `{"time":`, wantErr: true},
+		"json missing msg": {line: `{"time":"2024-01-02T15:04:05Z","level":"info"}`, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := ParseLogRecord(tt.line)
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("ParseLogRecord() error = %v, wantErr %t", gotErr, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLogRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogQuery_Matches(t *testing.T) {
+	timestamp := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+	record := LogRecord{
+		Time:    timestamp,
+		Level:   output.Warning,
+		Message: "disk nearly full",
+		Fields:  map[string]any{"free": "10MB", "count": json.Number("3")},
+	}
+	tests := map[string]struct {
+		q    LogQuery
+		want bool
+	}{
+		"zero value":          {q: LogQuery{}, want: true},
+		"level included":      {q: LogQuery{Levels: LogLevelsAtLeast(output.Warning)}, want: true},
+		"level excluded":      {q: LogQuery{Levels: LogLevelsAtLeast(output.Error)}, want: false},
+		"since":               {q: LogQuery{Since: timestamp}, want: true},
+		"since excluded":      {q: LogQuery{Since: timestamp.Add(time.Second)}, want: false},
+		"until":               {q: LogQuery{Until: timestamp}, want: true},
+		"until excluded":      {q: LogQuery{Until: timestamp.Add(-time.Second)}, want: false},
+		"message":             {q: LogQuery{Message: regexp.MustCompile(`nearly`)}, want: true},
+		"message excluded":    {q: LogQuery{Message: regexp.MustCompile(`^nearly`)}, want: false},
+		"fields":              {q: LogQuery{Fields: map[string]string{"free": "10MB", "count": "3"}}, want: true},
+		"field value differs": {q: LogQuery{Fields: map[string]string{"free": "20MB"}}, want: false},
+		"field missing":       {q: LogQuery{Fields: map[string]string{"path": ""}}, want: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.q.Matches(record); got != tt.want {
+				t.Errorf("LogQuery.Matches() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogLevelsAtLeast(t *testing.T) {
+	if got, want := LogLevelsAtLeast(output.Error), []output.Level{output.Fatal, output.Panic, output.Error}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LogLevelsAtLeast() = %v, want %v", got, want)
+	}
+	if got := LogLevelsAtLeast(output.Trace); len(got) != 7 {
+		t.Errorf("LogLevelsAtLeast() = %v, want all levels", got)
+	}
+}
+
+// writeTestLogs creates, in a new memory file system, a log directory holding a compressed
+// file for the day before yesterday, an uncompressed file for yesterday, an undated file,
+// and a file for today
+func writeTestLogs(t *testing.T) string {
+	t.Helper()
+	fileSystem = afero.NewMemMapFs()
+	dir := filepath.Join("state", "app", "logs")
+	_ = fileSystem.MkdirAll(dir, StdDirPermissions)
+	now := time.Now()
+	record := func(daysAgo int, l output.Level, msg string, fields map[string]any) string {
+		return formatTextRecord(l, now.AddDate(0, 0, -daysAgo), msg, fields) + "\n"
+	}
+	name := func(daysAgo int) string {
+		return filepath.Join(dir, "app."+now.AddDate(0, 0, -daysAgo).Format(logFileDateLayout)+logFileExtension)
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(record(2, output.Info, "started", map[string]any{"command": "list"}) +
+		record(2, output.Error, "failed", map[string]any{"command": "list"})))
+	_ = gz.Close()
+	_ = afero.WriteFile(fileSystem, name(2)+".gz", compressed.Bytes(), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, name(1), []byte(record(1, output.Warning, "slow", nil)+
+		"not a record\n"), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, name(0), []byte(record(0, output.Info, "started", map[string]any{"command": "show"})+
+		renderRecord(JSONLinesLogFormat, output.Error, now, "failed", map[string]any{"command": "show"})+"\n"+
+		`time="2024`), StdFilePermissions)
+	_ = afero.WriteFile(fileSystem, filepath.Join(dir, "other.log"), []byte(record(0, output.Error, "other", nil)),
+		StdFilePermissions)
+	return dir
+}
+
+func TestQueryLogs(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalLogPath := logPath
+	defer func() {
+		fileSystem = originalFileSystem
+		logPath = originalLogPath
+	}()
+	logPath = writeTestLogs(t)
+	tests := map[string]struct {
+		q    LogQuery
+		want []string
+	}{
+		"everything": {
+			want: []string{"info started", "error failed", "warning slow", "info started", "error failed"},
+		},
+		"errors": {
+			q:    LogQuery{Levels: LogLevelsAtLeast(output.Error)},
+			want: []string{"error failed", "error failed"},
+		},
+		"field": {
+			q:    LogQuery{Fields: map[string]string{"command": "show"}},
+			want: []string{"info started", "error failed"},
+		},
+		"since": {
+			q:    LogQuery{Since: time.Now().Add(-time.Hour)},
+			want: []string{"info started", "error failed"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			records, ok := QueryLogs(o, "app", tt.q)
+			if !ok {
+				t.Errorf("QueryLogs() ok = false")
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, levelsToString[r.Level]+" "+r.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryLogs() = %v, want %v", got, tt.want)
+			}
+			o.Report(t, "QueryLogs()", output.WantedRecording{})
+		})
+	}
+}
+
+func TestQueryLogs_problems(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalLogPath := logPath
+	defer func() {
+		fileSystem = originalFileSystem
+		logPath = originalLogPath
+	}()
+	t.Run("not initialized", func(t *testing.T) {
+		logPath = ""
+		o := output.NewRecorder()
+		if _, ok := QueryLogs(o, "app", LogQuery{}); ok {
+			t.Errorf("QueryLogs() ok = true")
+		}
+		o.Report(t, "QueryLogs()", output.WantedRecording{
+			Error: "The log cannot be read, as logging has not been initialized.\n",
+		})
+	})
+	t.Run("corrupt compressed file", func(t *testing.T) {
+		logPath = writeTestLogs(t)
+		fileName := filepath.Join(logPath, "app.20000101.log.gz")
+		_ = afero.WriteFile(fileSystem, fileName, []byte("not compressed"), StdFilePermissions)
+		o := output.NewRecorder()
+		records, ok := QueryLogs(o, "app", LogQuery{})
+		if ok || len(records) != 5 {
+			t.Errorf("QueryLogs() = %d records, %t", len(records), ok)
+		}
+		o.Report(t, "QueryLogs()", output.WantedRecording{
+			Error: "The log file \"" + fileName + "\" cannot be read: 'gzip: invalid header'.\n",
+		})
+	})
+}
+
+func TestFollowLogs(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalLogPath := logPath
+	originalInterval := logFollowInterval
+	defer func() {
+		fileSystem = originalFileSystem
+		logPath = originalLogPath
+		logFollowInterval = originalInterval
+	}()
+	logPath = writeTestLogs(t)
+	logFollowInterval = 10 * time.Millisecond
+	var lock sync.Mutex
+	var got []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		done <- FollowLogs(ctx, output.NewNilBus(), "app", LogQuery{Levels: LogLevelsAtLeast(output.Info)},
+			func(r LogRecord) {
+				lock.Lock()
+				defer lock.Unlock()
+				got = append(got, r.Message)
+			})
+	}()
+	count := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(got)
+	}
+	waitFor := func(n int) {
+		for deadline := time.Now().Add(5 * time.Second); count() < n && time.Now().Before(deadline); {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor(5)
+	today := filepath.Join(logPath, "app."+time.Now().Format(logFileDateLayout)+logFileExtension)
+	f, _ := fileSystem.OpenFile(today, os.O_WRONLY|os.O_CREATE|os.O_APPEND, StdFilePermissions)
+	// complete the partial record, and add a debug record, which the query excludes
+	_, _ = f.WriteString(`-01-02T15:04:05Z" level=info msg=completed` + "\n")
+	_, _ = f.WriteString(formatTextRecord(output.Debug, time.Now(), "hidden", nil) + "\n")
+	_ = f.Close()
+	waitFor(6)
+	tomorrow := filepath.Join(logPath, "app."+time.Now().AddDate(0, 0, 1).Format(logFileDateLayout)+logFileExtension)
+	_ = afero.WriteFile(fileSystem, tomorrow, []byte(formatTextRecord(output.Info, time.Now(), "rotated", nil)+"\n"),
+		StdFilePermissions)
+	waitFor(7)
+	cancel()
+	if ok := <-done; !ok {
+		t.Errorf("FollowLogs() = false")
+	}
+	want := []string{"started", "failed", "slow", "started", "failed", "completed", "rotated"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FollowLogs() = %v, want %v", got, want)
+	}
+}
+
+func Test_readLogLine(t *testing.T) {
+	long := strings.Repeat("x", maxLogLineLength+100)
+	reader := bufio.NewReaderSize(strings.NewReader(long+"\nshort\npartial"), 16)
+	line, length, err := readLogLine(reader)
+	if len(line) != maxLogLineLength || length != len(long)+1 || err != nil {
+		t.Errorf("readLogLine() = %d bytes, %d, %v", len(line), length, err)
+	}
+	line, length, err = readLogLine(reader)
+	if string(line) != "short\n" || length != 6 || err != nil {
+		t.Errorf("readLogLine() = %q, %d, %v", line, length, err)
+	}
+	line, length, err = readLogLine(reader)
+	if string(line) != "partial" || length != 7 || err == nil {
+		t.Errorf("readLogLine() = %q, %d, %v", line, length, err)
+	}
+}
@@ -0,0 +1,207 @@
+package cmd_toolkit
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file decides which log files to keep when logging is initialized; the
+// log files are named <application name>.YYYYMMDD.log, one per day, with .gz appended once
+// they are compressed, and are ordered by the date in their names, so that copying or
+// touching a file does not change its place
+
+const (
+	// LogRetentionSection is the configuration section read by
+	// RetentionPolicyFromConfiguration
+	LogRetentionSection = "logging"
+	maxFilesKey         = "maxFiles"
+	maxAgeKey           = "maxAge"
+	maxTotalBytesKey    = "maxTotalBytes"
+	logFileDateLayout   = "20060102"
+)
+
+// RetentionPolicy specifies which log files are deleted when logging is initialized; a
+// file is deleted if it breaks any of the limits, and a zero limit is no limit
+type RetentionPolicy struct {
+	// MaxFiles is the number of the most recent log files to keep
+	MaxFiles int
+	// MaxAge is how long to keep a log file after the end of the day that it covers
+	MaxAge time.Duration
+	// MaxTotalBytes is the combined size of the log files to keep; the oldest files are
+	// deleted until the remaining files fit
+	MaxTotalBytes int64
+}
+
+// DefaultRetentionPolicy keeps the 10 most recent log files
+var DefaultRetentionPolicy = RetentionPolicy{MaxFiles: 10}
+
+// WithRetentionPolicy selects the policy for deleting old log files; the default is
+// DefaultRetentionPolicy
+func WithRetentionPolicy(p RetentionPolicy) LogOption {
+	return func(sl *simpleLogger) {
+		sl.retention = p
+	}
+}
+
+// RetentionPolicyFromConfiguration reads a RetentionPolicy from the "logging" section of the
+// configuration, e.g.,
+//
+//	logging:
+//	    maxFiles: 10
+//	    maxAge: 720h
+//	    maxTotalBytes: 104857600
+//
+// maxAge is a duration, as understood by time.ParseDuration; settings that are not defined
+// keep their values in DefaultRetentionPolicy. Invalid settings are reported, and the
+// returned bool is false if there were any
+func RetentionPolicyFromConfiguration(o output.Bus, c *Configuration) (RetentionPolicy, bool) {
+	policy := DefaultRetentionPolicy
+	section := c.SubConfiguration(LogRetentionSection)
+	ok := true
+	maxFiles, filesErr := retentionLimit(section, maxFilesKey, policy.MaxFiles)
+	if filesErr != nil {
+		reportInvalidRetentionSetting(o, maxFilesKey, filesErr)
+		ok = false
+	}
+	policy.MaxFiles = maxFiles
+	maxTotalBytes, bytesErr := retentionLimit(section, maxTotalBytesKey, int(policy.MaxTotalBytes))
+	if bytesErr != nil {
+		reportInvalidRetentionSetting(o, maxTotalBytesKey, bytesErr)
+		ok = false
+	}
+	policy.MaxTotalBytes = int64(maxTotalBytes)
+	if section.hasKey(maxAgeKey) {
+		maxAge, ageErr := retentionAge(section)
+		if ageErr != nil {
+			reportInvalidRetentionSetting(o, maxAgeKey, ageErr)
+			ok = false
+		} else {
+			policy.MaxAge = maxAge
+		}
+	}
+	return policy, ok
+}
+
+// retentionLimit reads a count or a size; a negative value is rejected rather than clamped
+// to 0, which would quietly remove the limit
+func retentionLimit(section *Configuration, key string, defaultValue int) (int, error) {
+	value, valueErr := section.IntDefault(key, NewIntBounds(math.MinInt, defaultValue, math.MaxInt))
+	switch {
+	case valueErr != nil:
+		return defaultValue, valueErr
+	case value < 0:
+		return defaultValue, fmt.Errorf("invalid value %d for %s: the limit cannot be negative", value, key)
+	}
+	return value, nil
+}
+
+func retentionAge(section *Configuration) (time.Duration, error) {
+	if _, isInt := section.IntMap[maxAgeKey]; isInt {
+		return 0, fmt.Errorf("a duration, such as 720h, is required for %s", maxAgeKey)
+	}
+	if _, isBool := section.BoolMap[maxAgeKey]; isBool {
+		return 0, fmt.Errorf("a duration, such as 720h, is required for %s", maxAgeKey)
+	}
+	value, valueErr := section.StringDefault(maxAgeKey, "")
+	if valueErr != nil {
+		return 0, valueErr
+	}
+	age, parseErr := time.ParseDuration(value)
+	switch {
+	case parseErr != nil:
+		return 0, fmt.Errorf("invalid value %q for %s: not a duration, such as 720h", value, maxAgeKey)
+	case age < 0:
+		return 0, fmt.Errorf("invalid value %q for %s: the duration cannot be negative", value, maxAgeKey)
+	}
+	return age, nil
+}
+
+// logFile is a log file found in the log directory
+type logFile struct {
+	name string
+	// covered is the start of the day that the file covers, or, if its name has no date, the
+	// time it was last modified
+	covered    time.Time
+	dated      bool
+	compressed bool
+	size       int64
+}
+
+// newLogFile creates a logFile from a file whose name is known to start with the prefix and
+// end with the log file extension, or the compressed log file extension
+func newLogFile(file fs.FileInfo, prefix string) logFile {
+	lf := logFile{name: file.Name(), covered: file.ModTime(), size: file.Size()}
+	datePart := strings.TrimPrefix(lf.name, prefix)
+	if strings.HasSuffix(datePart, compressedLogFileExtension) {
+		lf.compressed = true
+		datePart = strings.TrimSuffix(datePart, compressedLogFileExtension)
+	} else {
+		datePart = strings.TrimSuffix(datePart, logFileExtension)
+	}
+	if date, parseErr := time.ParseInLocation(logFileDateLayout, datePart, time.Local); parseErr == nil {
+		lf.covered = date
+		lf.dated = true
+	}
+	return lf
+}
+
+// ended returns the time at which the file stopped receiving records
+func (lf logFile) ended() time.Time {
+	if lf.dated {
+		return lf.covered.AddDate(0, 0, 1)
+	}
+	return lf.covered
+}
+
+// sortLogFiles sorts the files from oldest to newest; files covering the same time are
+// sorted by name
+func sortLogFiles(files []logFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].covered.Equal(files[j].covered) {
+			return files[i].covered.Before(files[j].covered)
+		}
+		return files[i].name < files[j].name
+	})
+}
+
+// expired returns how many of the files, which are sorted from oldest to newest, are to be
+// deleted; as the oldest files go first, the result is the number of files at the start of
+// the slice to delete
+func (p RetentionPolicy) expired(files []logFile, now time.Time) int {
+	count := 0
+	if p.MaxFiles > 0 && len(files) > p.MaxFiles {
+		count = len(files) - p.MaxFiles
+	}
+	if p.MaxAge > 0 {
+		cutoff := now.Add(-p.MaxAge)
+		for k := count; k < len(files) && files[k].ended().Before(cutoff); k++ {
+			count = k + 1
+		}
+	}
+	if p.MaxTotalBytes > 0 {
+		var total int64
+		for k := len(files) - 1; k >= count; k-- {
+			total += files[k].size
+			if total > p.MaxTotalBytes {
+				count = k + 1
+				break
+			}
+		}
+	}
+	return count
+}
+
+func reportInvalidRetentionSetting(o output.Bus, key string, e error) {
+	o.ErrorPrintf("The configuration setting %s.%s cannot be used: %s.\n", LogRetentionSection, key, e)
+	o.Log(output.Error, "invalid log retention setting", map[string]any{
+		"section": LogRetentionSection,
+		"key":     key,
+		"error":   e,
+	})
+}
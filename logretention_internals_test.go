@@ -0,0 +1,178 @@
+package cmd_toolkit
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/afero"
+)
+
+func Test_RetentionPolicy_expired(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.Local)
+	day := func(d int) logFile {
+		return logFile{
+			name:    "app." + time.Date(2024, time.March, d, 0, 0, 0, 0, time.Local).Format(logFileDateLayout) + ".log",
+			covered: time.Date(2024, time.March, d, 0, 0, 0, 0, time.Local),
+			dated:   true,
+			size:    100,
+		}
+	}
+	files := []logFile{day(1), day(3), day(5), day(7), day(9), day(10)}
+	tests := map[string]struct {
+		p    RetentionPolicy
+		want int
+	}{
+		"no limits":                  {p: RetentionPolicy{}, want: 0},
+		"count not exceeded":         {p: RetentionPolicy{MaxFiles: 6}, want: 0},
+		"count exceeded":             {p: RetentionPolicy{MaxFiles: 4}, want: 2},
+		"age":                        {p: RetentionPolicy{MaxAge: 72 * time.Hour}, want: 3},
+		"age counts from end of day": {p: RetentionPolicy{MaxAge: 108 * time.Hour}, want: 2},
+		"size":                       {p: RetentionPolicy{MaxTotalBytes: 250}, want: 4},
+		"size exactly met":           {p: RetentionPolicy{MaxTotalBytes: 300}, want: 3},
+		"size too small for any":     {p: RetentionPolicy{MaxTotalBytes: 50}, want: 6},
+		"strictest limit wins": {
+			p:    RetentionPolicy{MaxFiles: 5, MaxAge: 200 * time.Hour, MaxTotalBytes: 350},
+			want: 3,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.p.expired(files, now); got != tt.want {
+				t.Errorf("RetentionPolicy.expired() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cleanup_retention(t *testing.T) {
+	originalFileSystem := fileSystem
+	defer func() {
+		fileSystem = originalFileSystem
+	}()
+	today := time.Now()
+	dated := func(daysAgo int) string {
+		return "app." + today.AddDate(0, 0, -daysAgo).Format(logFileDateLayout) + logFileExtension
+	}
+	tests := map[string]struct {
+		files         map[string][]byte
+		modifications map[string]time.Time
+		policy        RetentionPolicy
+		wantFound     int
+		wantRemaining []string
+	}{
+		"ordered by name, not modification time": {
+			files: map[string][]byte{
+				dated(3): []byte("a"),
+				dated(2): []byte("b"),
+				dated(1): []byte("c"),
+			},
+			modifications: map[string]time.Time{
+				dated(3): today,
+				dated(2): today.Add(-time.Hour),
+				dated(1): today.Add(-2 * time.Hour),
+			},
+			policy:        RetentionPolicy{MaxFiles: 2},
+			wantFound:     3,
+			wantRemaining: []string{dated(1) + ".gz", dated(2) + ".gz"},
+		},
+		"identical modification times": {
+			files: map[string][]byte{
+				"app.a.log": []byte("a"),
+				"app.b.log": []byte("b"),
+				"app.c.log": []byte("c"),
+			},
+			modifications: map[string]time.Time{
+				"app.a.log": today,
+				"app.b.log": today,
+				"app.c.log": today,
+			},
+			policy:        RetentionPolicy{MaxFiles: 1},
+			wantFound:     3,
+			wantRemaining: []string{"app.c.log"},
+		},
+		"age": {
+			files: map[string][]byte{
+				dated(0):  []byte("a"),
+				dated(5):  []byte("b"),
+				dated(40): []byte("c"),
+			},
+			policy:        RetentionPolicy{MaxAge: 30 * 24 * time.Hour},
+			wantFound:     3,
+			wantRemaining: []string{dated(0), dated(5) + ".gz"},
+		},
+		"total size": {
+			files: map[string][]byte{
+				"app.a.log": make([]byte, 40),
+				"app.b.log": make([]byte, 40),
+				"app.c.log": make([]byte, 40),
+			},
+			modifications: map[string]time.Time{
+				"app.a.log": today.Add(-2 * time.Hour),
+				"app.b.log": today.Add(-time.Hour),
+				"app.c.log": today,
+			},
+			policy:        RetentionPolicy{MaxTotalBytes: 100},
+			wantFound:     3,
+			wantRemaining: []string{"app.b.log", "app.c.log"},
+		},
+		"newest file kept": {
+			files: map[string][]byte{
+				"app.a.log": make([]byte, 40),
+				"app.b.log": make([]byte, 40),
+			},
+			modifications: map[string]time.Time{
+				"app.a.log": today.Add(-time.Hour),
+				"app.b.log": today,
+			},
+			policy:        RetentionPolicy{MaxTotalBytes: 10},
+			wantFound:     2,
+			wantRemaining: []string{"app.b.log"},
+		},
+		"other files ignored": {
+			files: map[string][]byte{
+				dated(0):                []byte("a"),
+				dated(1):                []byte("b"),
+				"other." + dated(2)[4:]: []byte("c"),
+				"app.notes.txt":         []byte("d"),
+			},
+			policy:        RetentionPolicy{MaxFiles: 1},
+			wantFound:     2,
+			wantRemaining: []string{"app.notes.txt", dated(0), "other." + dated(2)[4:]},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fileSystem = afero.NewMemMapFs()
+			_ = fileSystem.Mkdir("logs", StdDirPermissions)
+			for fileName, content := range tt.files {
+				path := filepath.Join("logs", fileName)
+				_ = afero.WriteFile(fileSystem, path, content, StdFilePermissions)
+				if modified, ok := tt.modifications[fileName]; ok {
+					_ = fileSystem.Chtimes(path, modified, modified)
+				}
+			}
+			o := output.NewRecorder()
+			gotFound, gotDeleted := cleanup(o, "logs", "app", tt.policy)
+			if gotFound != tt.wantFound {
+				t.Errorf("cleanup() found %d want %d", gotFound, tt.wantFound)
+			}
+			if wantDeleted := len(tt.files) - len(tt.wantRemaining); gotDeleted != wantDeleted {
+				t.Errorf("cleanup() deleted %d want %d", gotDeleted, wantDeleted)
+			}
+			entries, _ := afero.ReadDir(fileSystem, "logs")
+			var remaining []string
+			for _, entry := range entries {
+				remaining = append(remaining, entry.Name())
+			}
+			wantRemaining := slices.Clone(tt.wantRemaining)
+			slices.Sort(wantRemaining)
+			if !slices.Equal(remaining, wantRemaining) {
+				t.Errorf("cleanup() left %v want %v", remaining, wantRemaining)
+			}
+			o.Report(t, "cleanup()", output.WantedRecording{})
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cmd_toolkit_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+func TestRetentionPolicyFromConfiguration(t *testing.T) {
+	withLogging := func(c *cmdtoolkit.Configuration) *cmdtoolkit.Configuration {
+		return &cmdtoolkit.Configuration{
+			ConfigurationMap: map[string]*cmdtoolkit.Configuration{"logging": c},
+		}
+	}
+	tests := map[string]struct {
+		c      *cmdtoolkit.Configuration
+		want   cmdtoolkit.RetentionPolicy
+		wantOk bool
+		output.WantedRecording
+	}{
+		"no configuration": {
+			c:      cmdtoolkit.EmptyConfiguration(),
+			want:   cmdtoolkit.DefaultRetentionPolicy,
+			wantOk: true,
+		},
+		"all settings": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				IntMap:    map[string]int{"maxFiles": 30, "maxTotalBytes": 1048576},
+				StringMap: map[string]string{"maxAge": "720h"},
+			}),
+			want:   cmdtoolkit.RetentionPolicy{MaxFiles: 30, MaxAge: 720 * time.Hour, MaxTotalBytes: 1048576},
+			wantOk: true,
+		},
+		"numbers as strings": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				StringMap: map[string]string{"maxFiles": "0", "maxTotalBytes": "2048"},
+			}),
+			want:   cmdtoolkit.RetentionPolicy{MaxFiles: 0, MaxTotalBytes: 2048},
+			wantOk: true,
+		},
+		"negative values": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				IntMap:    map[string]int{"maxFiles": -1},
+				StringMap: map[string]string{"maxTotalBytes": "-2048"},
+			}),
+			want:   cmdtoolkit.DefaultRetentionPolicy,
+			wantOk: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration setting logging.maxFiles cannot be used: invalid value -1 for maxFiles:" +
+					" the limit cannot be negative.\n" +
+					"The configuration setting logging.maxTotalBytes cannot be used: invalid value -2048 for" +
+					" maxTotalBytes: the limit cannot be negative.\n",
+				Log: "" +
+					"level='error' error='invalid value -1 for maxFiles: the limit cannot be negative'" +
+					" key='maxFiles' section='logging' msg='invalid log retention setting'\n" +
+					"level='error' error='invalid value -2048 for maxTotalBytes: the limit cannot be negative'" +
+					" key='maxTotalBytes' section='logging' msg='invalid log retention setting'\n",
+			},
+		},
+		"invalid values": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				StringMap: map[string]string{"maxFiles": "many", "maxAge": "a month"},
+			}),
+			want:   cmdtoolkit.DefaultRetentionPolicy,
+			wantOk: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration setting logging.maxFiles cannot be used: invalid value \"many\" for flag" +
+					" --maxFiles: parse error.\n" +
+					"The configuration setting logging.maxAge cannot be used: invalid value \"a month\" for" +
+					" maxAge: not a duration, such as 720h.\n",
+				Log: "" +
+					"level='error' error='invalid value \"many\" for flag --maxFiles: parse error' key='maxFiles'" +
+					" section='logging' msg='invalid log retention setting'\n" +
+					"level='error' error='invalid value \"a month\" for maxAge: not a duration, such as 720h'" +
+					" key='maxAge' section='logging' msg='invalid log retention setting'\n",
+			},
+		},
+		"negative age": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				StringMap: map[string]string{"maxAge": "-1h"},
+			}),
+			want:   cmdtoolkit.DefaultRetentionPolicy,
+			wantOk: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration setting logging.maxAge cannot be used: invalid value \"-1h\" for" +
+					" maxAge: the duration cannot be negative.\n",
+				Log: "" +
+					"level='error' error='invalid value \"-1h\" for maxAge: the duration cannot be negative'" +
+					" key='maxAge' section='logging' msg='invalid log retention setting'\n",
+			},
+		},
+		"age as a number": {
+			c: withLogging(&cmdtoolkit.Configuration{
+				IntMap: map[string]int{"maxAge": 30},
+			}),
+			want:   cmdtoolkit.DefaultRetentionPolicy,
+			wantOk: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The configuration setting logging.maxAge cannot be used: a duration, such as 720h," +
+					" is required for maxAge.\n",
+				Log: "" +
+					"level='error' error='a duration, such as 720h, is required for maxAge'" +
+					" key='maxAge' section='logging' msg='invalid log retention setting'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			got, gotOk := cmdtoolkit.RetentionPolicyFromConfiguration(o, tt.c)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RetentionPolicyFromConfiguration() got = %v, want %v", got, tt.want)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("RetentionPolicyFromConfiguration() gotOk = %t, want %t", gotOk, tt.wantOk)
+			}
+			o.Report(t, "RetentionPolicyFromConfiguration()", tt.WantedRecording)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package cmd_toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+)
+
+// the code in this file provides a ready-made command for reading the application's log
+
+const (
+	logsCommandName   = "logs"
+	logsFollowFlag    = "follow"
+	logsLevelFlag     = "level"
+	logsSinceFlag     = "since"
+	logsUntilFlag     = "until"
+	logsMessageFlag   = "message"
+	logsFieldFlag     = "field"
+	logsTimeFlagUsage = "an RFC 3339 time, such as 2024-01-02T15:04:05Z, or a duration before now, such as 90m"
+)
+
+// NewLogsCommand returns a cobra command, named logs, that writes the records that its flags
+// select from the application's log files to the console; with --follow, it goes on writing
+// records as they are logged, until it is interrupted. The command's flags are registered
+// with AddDefaults, and their defaults are read from the logs section of the configuration.
+// Logging must be initialized before the command runs
+func NewLogsCommand(o output.Bus, c *Configuration, applicationName string) *cobra.Command {
+	set := logsFlagSet()
+	AddDefaults(set)
+	cmd := &cobra.Command{
+		Use:   logsCommandName,
+		Short: "Show records from the log",
+		Long: "Show records from the log, including the compressed records of past days;" +
+			" flags select the records by level, time, message, and field values",
+		Args: cobra.NoArgs,
+		// the command reports its own errors, which are not helped by the usage text
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runLogsCommand(o, cmd, set, applicationName)
+		},
+	}
+	AddFlags(o, c, cmd.Flags(), set)
+	return cmd
+}
+
+func logsFlagSet() *FlagSet {
+	return &FlagSet{
+		Name: logsCommandName,
+		Details: map[string]*FlagDetails{
+			logsFollowFlag: {
+				AbbreviatedName: "f",
+				Usage:           "go on showing records as they are logged",
+				ExpectedType:    BoolType,
+				DefaultValue:    false,
+			},
+			logsLevelFlag: {
+				Usage:        "show records at this level or more severe: " + strings.Join(logLevelNames, ", "),
+				ExpectedType: StringType,
+				DefaultValue: "",
+				Completion:   CompletionHints{Values: logLevelNames},
+			},
+			logsSinceFlag: {
+				Usage:        "show records logged at or after this time: " + logsTimeFlagUsage,
+				ExpectedType: StringType,
+				DefaultValue: "",
+			},
+			logsUntilFlag: {
+				Usage:        "show records logged at or before this time: " + logsTimeFlagUsage,
+				ExpectedType: StringType,
+				DefaultValue: "",
+			},
+			logsMessageFlag: {
+				Usage:        "show records whose messages match this regular expression",
+				ExpectedType: StringType,
+				DefaultValue: "",
+			},
+			logsFieldFlag: {
+				Usage:        "show records with these field values, e.g., --field command=list,user=admin",
+				ExpectedType: StringType,
+				DefaultValue: "",
+			},
+		},
+	}
+}
+
+func runLogsCommand(o output.Bus, cmd *cobra.Command, set *FlagSet, applicationName string) error {
+	values, flagErrs := ReadFlags(cmd.Flags(), set)
+	if !ProcessFlagErrors(o, flagErrs) {
+		return ToErrorInterface(ExitErrorForFlagErrors(logsCommandName, flagErrs))
+	}
+	follow, followErr := GetBool(o, values, logsFollowFlag)
+	if followErr != nil {
+		return ToErrorInterface(NewExitProgrammingError(logsCommandName))
+	}
+	q, queryErr := logsQuery(o, values, time.Now())
+	if queryErr != nil {
+		var valueErr *FlagValueError
+		if !errors.As(queryErr, &valueErr) {
+			return ToErrorInterface(NewExitProgrammingError(logsCommandName))
+		}
+		reportFlagValueError(o, valueErr)
+		return ToErrorInterface(NewExitUserError(logsCommandName))
+	}
+	show := func(r LogRecord) {
+		o.ConsolePrintln(formatTextRecord(r.Level, r.Time, r.Message, r.Fields))
+	}
+	var ok bool
+	if follow.Value {
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		ok = FollowLogs(ctx, o, applicationName, q, show)
+	} else {
+		var records []LogRecord
+		records, ok = QueryLogs(o, applicationName, q)
+		for _, r := range records {
+			show(r)
+		}
+	}
+	if !ok {
+		return ToErrorInterface(NewExitSystemError(logsCommandName))
+	}
+	return nil
+}
+
+// logsQuery builds a LogQuery from the command's flag values; an unusable flag value is
+// returned as a *FlagValueError
+func logsQuery(o output.Bus, values map[string]*CommandFlag[any], now time.Time) (LogQuery, error) {
+	var q LogQuery
+	settings := map[string]string{}
+	for _, flagName := range []string{logsLevelFlag, logsSinceFlag, logsUntilFlag, logsMessageFlag, logsFieldFlag} {
+		flag, flagErr := GetString(o, values, flagName)
+		if flagErr != nil {
+			return q, flagErr
+		}
+		settings[flagName] = flag.Value
+	}
+	if level := settings[logsLevelFlag]; level != "" {
+		l, parseErr := ParseLogLevel(level)
+		if parseErr != nil {
+			return q, &FlagValueError{Flag: logsLevelFlag, Value: level, Err: parseErr}
+		}
+		q.Levels = LogLevelsAtLeast(l)
+	}
+	var timeErr error
+	if q.Since, timeErr = logsTime(logsSinceFlag, settings[logsSinceFlag], now); timeErr != nil {
+		return q, timeErr
+	}
+	if q.Until, timeErr = logsTime(logsUntilFlag, settings[logsUntilFlag], now); timeErr != nil {
+		return q, timeErr
+	}
+	if message := settings[logsMessageFlag]; message != "" {
+		pattern, compileErr := regexp.Compile(message)
+		if compileErr != nil {
+			return q, &FlagValueError{Flag: logsMessageFlag, Value: message, Err: compileErr}
+		}
+		q.Message = pattern
+	}
+	if fields := settings[logsFieldFlag]; fields != "" {
+		q.Fields = map[string]string{}
+		for _, field := range strings.Split(fields, ",") {
+			key, value, found := strings.Cut(field, "=")
+			if !found || key == "" {
+				return q, &FlagValueError{Flag: logsFieldFlag, Value: field, Err: errors.New("it is not of the form key=value")}
+			}
+			q.Fields[key] = value
+		}
+	}
+	return q, nil
+}
+
+// logsTime parses a time flag's value, which is either an RFC 3339 time or a duration before
+// now
+func logsTime(flagName, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, parseErr := time.Parse(time.RFC3339, value); parseErr == nil {
+		return t, nil
+	}
+	if d, parseErr := time.ParseDuration(value); parseErr == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, &FlagValueError{
+		Flag:  flagName,
+		Value: value,
+		Err:   fmt.Errorf("not %s", logsTimeFlagUsage),
+	}
+}
@@ -0,0 +1,132 @@
+package cmd_toolkit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+func TestNewLogsCommand(t *testing.T) {
+	originalFileSystem := fileSystem
+	originalLogPath := logPath
+	originalInterval := logFollowInterval
+	originalSettings := defaultConfigurationSettings
+	originalRegisteredFlagSets := registeredFlagSets
+	defer func() {
+		fileSystem = originalFileSystem
+		logPath = originalLogPath
+		logFollowInterval = originalInterval
+		defaultConfigurationSettings = originalSettings
+		registeredFlagSets = originalRegisteredFlagSets
+	}()
+	defaultConfigurationSettings = map[string]map[string]any{}
+	registeredFlagSets = map[string]*FlagSet{}
+	logPath = writeTestLogs(t)
+	logFollowInterval = 10 * time.Millisecond
+	tests := map[string]struct {
+		config    *Configuration
+		args      []string
+		wantErr   bool
+		wantLines []string
+		wantError string
+	}{
+		"all records": {
+			args: nil,
+			wantLines: []string{
+				"level=info msg=started command=list",
+				"level=error msg=failed command=list",
+				"level=warning msg=slow",
+				"level=info msg=started command=show",
+				"level=error msg=failed command=show",
+			},
+		},
+		"selected records": {
+			args:      []string{"--level", "error", "--field", "command=show", "--message", "^fail", "--since", "1h"},
+			wantLines: []string{"level=error msg=failed command=show"},
+		},
+		"several fields": {
+			args:      []string{"--field", "command=show,missing="},
+			wantLines: nil,
+		},
+		"configured level": {
+			config: &Configuration{
+				StringMap: map[string]string{},
+				ConfigurationMap: map[string]*Configuration{
+					logsCommandName: {StringMap: map[string]string{logsLevelFlag: "error"}},
+				},
+			},
+			args:      nil,
+			wantLines: []string{"level=error msg=failed command=list", "level=error msg=failed command=show"},
+		},
+		"until": {
+			args:      []string{"--until", "2000-01-01T00:00:00Z"},
+			wantLines: nil,
+		},
+		"follow": {
+			args:      []string{"--follow", "--level=warning"},
+			wantLines: []string{"level=error msg=failed command=list", "level=warning msg=slow", "level=error msg=failed command=show"},
+		},
+		"bad level": {
+			args:      []string{"--level", "loud"},
+			wantErr:   true,
+			wantError: "The value \"loud\" for flag --level cannot be used: \"loud\" is not a log level;",
+		},
+		"bad time": {
+			args:      []string{"--since", "yesterday"},
+			wantErr:   true,
+			wantError: "The value \"yesterday\" for flag --since cannot be used: not an RFC 3339 time,",
+		},
+		"bad message": {
+			args:      []string{"--message", "("},
+			wantErr:   true,
+			wantError: "The value \"(\" for flag --message cannot be used: error parsing regexp",
+		},
+		"bad field": {
+			args:      []string{"--field", "command"},
+			wantErr:   true,
+			wantError: "The value \"command\" for flag --field cannot be used: it is not of the form key=value.",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			c := tt.config
+			if c == nil {
+				c = EmptyConfiguration()
+			}
+			cmd := NewLogsCommand(o, c, "app")
+			cmd.SetArgs(tt.args)
+			var cobraOutput bytes.Buffer
+			cmd.SetOut(&cobraOutput)
+			cmd.SetErr(&cobraOutput)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := cmd.ExecuteContext(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("logs error = %v, wantErr %t", err, tt.wantErr)
+			}
+			var gotLines []string
+			for _, line := range strings.Split(strings.TrimSpace(o.ConsoleOutput()), "\n") {
+				if line == "" {
+					continue
+				}
+				// strip the time, which depends on when the test runs
+				_, rest, _ := strings.Cut(line, " ")
+				gotLines = append(gotLines, rest)
+			}
+			if strings.Join(gotLines, "\n") != strings.Join(tt.wantLines, "\n") {
+				t.Errorf("logs wrote %q, want %q", gotLines, tt.wantLines)
+			}
+			if !strings.HasPrefix(o.ErrorOutput(), tt.wantError) {
+				t.Errorf("logs error output %q, want prefix %q", o.ErrorOutput(), tt.wantError)
+			}
+			if strings.Contains(cobraOutput.String(), "Usage:") {
+				t.Errorf("logs wrote its usage text: %q", cobraOutput.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package cmd_toolkit
+
+import (
+	"io"
+	"slices"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file lets the logger write each record to additional destinations, such
+// as stderr, besides the log file; each destination has its own level and format
+
+// LogSink is an additional destination for log records
+type LogSink struct {
+	// Writer receives the records
+	Writer io.Writer
+	// Level is the least severe level of the records that the sink receives; e.g., a sink
+	// whose Level is output.Warning receives warning, error, panic, and fatal records
+	Level output.Level
+	// Format is the format in which the sink receives the records
+	Format LogFormat
+}
+
+// LogSinkID identifies a sink added to the logger, so that it can be removed
+type LogSinkID uint64
+
+type registeredSink struct {
+	id LogSinkID
+	LogSink
+}
+
+// WithLogSink adds a sink to the logger when it is initialized; sinks added by earlier calls
+// to InitLogging or InitLoggingWithLevel are removed
+func WithLogSink(sink LogSink) LogOption {
+	return func(sl *simpleLogger) {
+		sl.addSink(sink)
+	}
+}
+
+// AddSink adds a sink to the logger, returning the sink's ID; it is safe to call while
+// other goroutines are logging
+func (sl *simpleLogger) AddSink(sink LogSink) LogSinkID {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	return sl.addSink(sink)
+}
+
+// RemoveSink removes the sink with the specified ID from the logger, returning false if
+// there is no such sink; it is safe to call while other goroutines are logging
+func (sl *simpleLogger) RemoveSink(id LogSinkID) bool {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	index := slices.IndexFunc(sl.sinks, func(s registeredSink) bool { return s.id == id })
+	if index < 0 {
+		return false
+	}
+	sl.sinks = slices.Delete(slices.Clone(sl.sinks), index, index+1)
+	sl.updateSinkThreshold()
+	return true
+}
+
+// addSink adds a sink; the caller is responsible for holding the lock, if necessary
+func (sl *simpleLogger) addSink(sink LogSink) LogSinkID {
+	sl.lastSinkID++
+	// the slice is replaced, rather than appended to, so that it can be shared safely
+	sl.sinks = append(slices.Clip(sl.sinks), registeredSink{id: sl.lastSinkID, LogSink: sink})
+	sl.updateSinkThreshold()
+	return sl.lastSinkID
+}
+
+func (sl *simpleLogger) removeSinks() {
+	sl.sinks = nil
+	sl.updateSinkThreshold()
+}
+
+// updateSinkThreshold records the least severe level received by any sink, so that WillLog
+// can consult it without acquiring the lock; it stores the level plus one, so that zero
+// means that there are no sinks
+func (sl *simpleLogger) updateSinkThreshold() {
+	var threshold uint32
+	for _, sink := range sl.sinks {
+		threshold = max(threshold, uint32(sink.Level)+1)
+	}
+	sl.sinkThreshold.Store(threshold)
+}
+
+// sinksWillLog returns true if any sink receives records at a specified level
+func (sl *simpleLogger) sinksWillLog(l output.Level) bool {
+	return uint32(l) < sl.sinkThreshold.Load()
+}
@@ -0,0 +1,128 @@
+package cmd_toolkit
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+func Test_recordBuffer_appendCompactRecord(t *testing.T) {
+	tests := map[string]struct {
+		l      output.Level
+		msg    string
+		fields map[string]any
+		want   string
+	}{
+		"no fields": {l: output.Warning, msg: "disk nearly full", want: "warning: disk nearly full"},
+		"fields": {
+			l:      output.Error,
+			msg:    "cannot open file",
+			fields: map[string]any{"path": "a b.txt", "attempt": 2},
+			want:   `error: cannot open file attempt=2 path="a b.txt"`,
+		},
+		"forged record": {
+			l:    output.Info,
+			msg:  "done\nerror: disk on fire",
+			want: `info: done\nerror: disk on fire`,
+		},
+		"other characters": {
+			l:    output.Info,
+			msg:  "tab\there, bell\a, separator\u2028, bad \xff, café",
+			want: `info: tab\there, bell\a, separator\u2028, bad \xff, café`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := renderRecord(CompactLogFormat, tt.l, time.Time{}, tt.msg, tt.fields); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_simpleLogger_sinks(t *testing.T) {
+	file := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	trace := &bytes.Buffer{}
+	sl := &simpleLogger{
+		writer:          file,
+		currentLogLevel: output.Debug,
+		lock:            &sync.RWMutex{},
+	}
+	stderrID := sl.AddSink(LogSink{Writer: stderr, Level: output.Warning, Format: CompactLogFormat})
+	traceID := sl.AddSink(LogSink{Writer: trace, Level: output.Trace, Format: JSONLinesLogFormat})
+	if stderrID == traceID {
+		t.Errorf("AddSink() returned the same ID, %d, twice", stderrID)
+	}
+	if !sl.WillLog(output.Trace) {
+		t.Errorf("WillLog(output.Trace) = false with a trace sink")
+	}
+	timestamp := time.Unix(0, 0).UTC()
+	sl.log(output.Trace, "tracing", nil)
+	sl.doLog(output.Info, timestamp, "informing", map[string]any{"n": 1})
+	sl.doLog(output.Error, timestamp, "failing", map[string]any{"n": 2})
+	wantFile := "" +
+		`time="1970-01-01T00:00:00Z" level=info msg=informing n=1` + "\n" +
+		`time="1970-01-01T00:00:00Z" level=error msg=failing n=2` + "\n"
+	if got := file.String(); got != wantFile {
+		t.Errorf("log file got %q, want %q", got, wantFile)
+	}
+	if got, want := stderr.String(), "error: failing n=2\n"; got != want {
+		t.Errorf("stderr sink got %q, want %q", got, want)
+	}
+	if got := trace.String(); !bytes.Contains([]byte(got), []byte(`"msg":"tracing"`)) ||
+		!bytes.HasSuffix([]byte(got), []byte(
+			`{"time":"1970-01-01T00:00:00Z","level":"info","msg":"informing","n":1}`+"\n"+
+				`{"time":"1970-01-01T00:00:00Z","level":"error","msg":"failing","n":2}`+"\n")) {
+		t.Errorf("trace sink got %q", got)
+	}
+	if !sl.RemoveSink(traceID) {
+		t.Errorf("RemoveSink() = false for an added sink")
+	}
+	if sl.RemoveSink(traceID) {
+		t.Errorf("RemoveSink() = true for a removed sink")
+	}
+	if sl.WillLog(output.Trace) {
+		t.Errorf("WillLog(output.Trace) = true after removing the trace sink")
+	}
+	if !sl.WillLog(output.Warning) {
+		t.Errorf("WillLog(output.Warning) = false")
+	}
+}
+
+func Test_simpleLogger_sinks_concurrency(t *testing.T) {
+	sl := &simpleLogger{
+		writer:          &bytes.Buffer{},
+		currentLogLevel: output.Info,
+		lock:            &sync.RWMutex{},
+	}
+	sink := &bytes.Buffer{}
+	var wg sync.WaitGroup
+	for k := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				sl.Info("message", map[string]any{"writer": k, "j": j})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				id := sl.AddSink(LogSink{Writer: sink, Level: output.Info, Format: CompactLogFormat})
+				sl.RemoveSink(id)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(sl.sinks) != 0 {
+		t.Errorf("sinks remaining: %v", sl.sinks)
+	}
+	if got := fmt.Sprint(sl.sinkThreshold.Load()); got != "0" {
+		t.Errorf("sink threshold = %s, want 0", got)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package cmd_toolkit
+
+const raceDetectorEnabled = false
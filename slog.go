@@ -0,0 +1,228 @@
+package cmd_toolkit
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"sort"
+
+	"github.com/majohn-r/output"
+)
+
+// the code in this file connects the log/slog package to output.Logger, in both directions:
+// NewSlogHandler lets code that logs through slog write to an output.Logger, such as
+// ProductionLogger, and NewSlogLogger lets an output.Logger be implemented by a slog.Logger
+
+// levelFilter is implemented by output.Loggers, such as ProductionLogger, that can tell
+// whether they will log messages at a specified level
+type levelFilter interface {
+	WillLog(l output.Level) bool
+}
+
+// slogHandler is a slog.Handler that writes records to an output.Logger
+type slogHandler struct {
+	logger output.Logger
+	prefix string
+	fields map[string]any
+}
+
+// NewSlogHandler returns a slog.Handler that writes records to the specified logger; to
+// send everything logged through slog to the production log, call
+//
+//	slog.SetDefault(slog.New(cmd_toolkit.NewSlogHandler(cmd_toolkit.ProductionLogger)))
+//
+// Attributes become fields; attributes in groups become fields whose keys are prefixed by
+// the group names, separated by periods, e.g., request.id. Records are logged at the level
+// nearest their slog level: trace below slog.LevelDebug, debug, info, warning, and error at
+// or above slog.LevelError; no record is logged at the panic or fatal level
+func NewSlogHandler(logger output.Logger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+// Enabled reports whether the logger will log records at the specified level
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if filter, ok := h.logger.(levelFilter); ok {
+		return filter.WillLog(fromSlogLevel(level))
+	}
+	return true
+}
+
+// Handle logs the record
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := maps.Clone(h.fields)
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, a)
+		return true
+	})
+	logAtLevel(h.logger, fromSlogLevel(r.Level), r.Message, fields)
+	return nil
+}
+
+// WithAttrs returns a handler that adds the attributes to every record
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := maps.Clone(h.fields)
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	for _, a := range attrs {
+		addSlogAttr(fields, h.prefix, a)
+	}
+	return &slogHandler{logger: h.logger, prefix: h.prefix, fields: fields}
+}
+
+// WithGroup returns a handler that places subsequent attributes in the named group
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, prefix: h.prefix + name + ".", fields: h.fields}
+}
+
+// addSlogAttr adds an attribute to the fields, following the slog.Handler rules: empty
+// attributes are ignored, and groups are flattened, their keys prefixed by the group name;
+// an empty group is ignored, and a group with an empty key is inlined
+func addSlogAttr(fields map[string]any, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			addSlogAttr(fields, groupPrefix, member)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.Any()
+}
+
+// fromSlogLevel converts a slog level to the nearest output.Level that does not stop the
+// program
+func fromSlogLevel(level slog.Level) output.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return output.Trace
+	case level < slog.LevelInfo:
+		return output.Debug
+	case level < slog.LevelWarn:
+		return output.Info
+	case level < slog.LevelError:
+		return output.Warning
+	default:
+		return output.Error
+	}
+}
+
+func logAtLevel(logger output.Logger, l output.Level, msg string, fields map[string]any) {
+	switch l {
+	case output.Trace:
+		logger.Trace(msg, fields)
+	case output.Debug:
+		logger.Debug(msg, fields)
+	case output.Info:
+		logger.Info(msg, fields)
+	case output.Warning:
+		logger.Warning(msg, fields)
+	default:
+		logger.Error(msg, fields)
+	}
+}
+
+// the slog levels used for output.Logger levels that slog does not define
+const (
+	// SlogLevelTrace is the slog level at which NewSlogLogger logs trace messages
+	SlogLevelTrace = slog.LevelDebug - 4
+	// SlogLevelPanic is the slog level at which NewSlogLogger logs panic messages
+	SlogLevelPanic = slog.LevelError + 4
+	// SlogLevelFatal is the slog level at which NewSlogLogger logs fatal messages
+	SlogLevelFatal = slog.LevelError + 8
+)
+
+var levelsToSlog = map[output.Level]slog.Level{
+	output.Trace:   SlogLevelTrace,
+	output.Debug:   slog.LevelDebug,
+	output.Info:    slog.LevelInfo,
+	output.Warning: slog.LevelWarn,
+	output.Error:   slog.LevelError,
+	output.Panic:   SlogLevelPanic,
+	output.Fatal:   SlogLevelFatal,
+}
+
+// slogLogger is an output.Logger that writes messages to a slog.Logger
+type slogLogger struct {
+	logger       *slog.Logger
+	exitFunction exitFunc
+}
+
+// NewSlogLogger returns an output.Logger that writes messages to the specified slog.Logger;
+// fields become attributes, sorted by key. Trace, panic, and fatal messages are logged at
+// SlogLevelTrace, SlogLevelPanic, and SlogLevelFatal, respectively. As with
+// ProductionLogger, Panic calls panic() and Fatal terminates the program after logging
+func NewSlogLogger(logger *slog.Logger) output.Logger {
+	return &slogLogger{logger: logger, exitFunction: os.Exit}
+}
+
+// WillLog returns true if the slog.Logger is enabled for messages at a specified level
+func (s *slogLogger) WillLog(l output.Level) bool {
+	return s.logger.Enabled(context.Background(), levelsToSlog[l])
+}
+
+func (s *slogLogger) log(l output.Level, msg string, fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, len(keys))
+	for index, k := range keys {
+		attrs[index] = slog.Any(k, fields[k])
+	}
+	s.logger.LogAttrs(context.Background(), levelsToSlog[l], msg, attrs...)
+}
+
+// Trace outputs a trace log message
+func (s *slogLogger) Trace(msg string, fields map[string]any) {
+	s.log(output.Trace, msg, fields)
+}
+
+// Debug outputs a debug log message
+func (s *slogLogger) Debug(msg string, fields map[string]any) {
+	s.log(output.Debug, msg, fields)
+}
+
+// Info outputs an info log message
+func (s *slogLogger) Info(msg string, fields map[string]any) {
+	s.log(output.Info, msg, fields)
+}
+
+// Warning outputs a warning log message
+func (s *slogLogger) Warning(msg string, fields map[string]any) {
+	s.log(output.Warning, msg, fields)
+}
+
+// Error outputs an error log message
+func (s *slogLogger) Error(msg string, fields map[string]any) {
+	s.log(output.Error, msg, fields)
+}
+
+// Panic outputs a panic log message and calls panic()
+func (s *slogLogger) Panic(msg string, fields map[string]any) {
+	s.log(output.Panic, msg, fields)
+	panic(msg)
+}
+
+// Fatal outputs a fatal log message and terminates the program
+func (s *slogLogger) Fatal(msg string, fields map[string]any) {
+	s.log(output.Fatal, msg, fields)
+	s.exitFunction(0)
+}
@@ -0,0 +1,30 @@
+package cmd_toolkit
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func Test_slogLogger_Fatal(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	exitCode := -1
+	logger := &slogLogger{
+		logger: slog.New(slog.NewTextHandler(buffer, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})),
+		exitFunction: func(code int) { exitCode = code },
+	}
+	logger.Fatal("fatal message", map[string]any{"k": "v"})
+	if got, want := buffer.String(), "level=ERROR+8 msg=\"fatal message\" k=v\n"; got != want {
+		t.Errorf("slogLogger.Fatal() logged %q, want %q", got, want)
+	}
+	if exitCode != 0 {
+		t.Errorf("slogLogger.Fatal() exit code = %d, want 0", exitCode)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd_toolkit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	cmdtoolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+type stringValuer string
+
+func (sv stringValuer) LogValue() slog.Value {
+	return slog.StringValue("resolved " + string(sv))
+}
+
+func TestNewSlogHandler(t *testing.T) {
+	tests := map[string]struct {
+		log  func(*slog.Logger)
+		want string
+	}{
+		"levels": {
+			log: func(l *slog.Logger) {
+				l.Log(context.Background(), slog.LevelDebug-4, "trace message")
+				l.Debug("debug message")
+				l.Info("info message")
+				l.Warn("warn message")
+				l.Error("error message")
+				l.Log(context.Background(), slog.LevelError+8, "severe message")
+			},
+			want: "" +
+				"level='trace'  msg='trace message'\n" +
+				"level='debug'  msg='debug message'\n" +
+				"level='info'  msg='info message'\n" +
+				"level='warning'  msg='warn message'\n" +
+				"level='error'  msg='error message'\n" +
+				"level='error'  msg='severe message'\n",
+		},
+		"attributes": {
+			log: func(l *slog.Logger) {
+				l.Info("attrs",
+					"count", 3,
+					slog.Bool("ok", true),
+					slog.Any("error", errors.New("oops")),
+					slog.Any("valuer", stringValuer("value")),
+					slog.Attr{},
+					slog.Duration("elapsed", time.Second))
+			},
+			want: "level='info' count='3' elapsed='1s' error='oops' ok='true' valuer='resolved value' msg='attrs'\n",
+		},
+		"groups": {
+			log: func(l *slog.Logger) {
+				l.With("app", "test").WithGroup("request").With("id", 7).WithGroup("").Info("grouped",
+					slog.Group("user", "name", "bob"),
+					slog.Group("", "inlined", 1),
+					slog.Group("empty"))
+			},
+			want: "level='info' app='test' request.id='7' request.inlined='1' request.user.name='bob' msg='grouped'\n",
+		},
+		"attributes are not shared": {
+			log: func(l *slog.Logger) {
+				parent := l.With("a", 1)
+				parent.With("b", 2).Info("child")
+				parent.Info("parent", "c", 3)
+			},
+			want: "" +
+				"level='info' a='1' b='2' msg='child'\n" +
+				"level='info' a='1' c='3' msg='parent'\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := output.NewRecordingLogger()
+			tt.log(slog.New(cmdtoolkit.NewSlogHandler(recorder)))
+			if got := recorder.String(); got != tt.want {
+				t.Errorf("NewSlogHandler() logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSlogHandler_Enabled(t *testing.T) {
+	originalLogWriterInitFn := cmdtoolkit.LogWriterInitFn
+	defer func() {
+		cmdtoolkit.LogWriterInitFn = originalLogWriterInitFn
+	}()
+	buffer := &bytes.Buffer{}
+	cmdtoolkit.LogWriterInitFn = func(_ output.Bus, _ string) (io.Writer, string) {
+		return buffer, "testingLogPath"
+	}
+	cmdtoolkit.InitLoggingWithLevel(nil, output.Warning, "", cmdtoolkit.WithLogFormat(cmdtoolkit.JSONLinesLogFormat))
+	defer cmdtoolkit.InitLoggingWithLevel(nil, output.Info, "")
+	handler := cmdtoolkit.NewSlogHandler(cmdtoolkit.ProductionLogger)
+	tests := map[string]struct {
+		level slog.Level
+		want  bool
+	}{
+		"debug": {level: slog.LevelDebug, want: false},
+		"info":  {level: slog.LevelInfo, want: false},
+		"warn":  {level: slog.LevelWarn, want: true},
+		"error": {level: slog.LevelError, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := handler.Enabled(context.Background(), tt.level); got != tt.want {
+				t.Errorf("Enabled() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+	slog.New(handler).Warn("written", slog.Group("g", "k", "v"))
+	slog.New(handler).Info("not written")
+	if got := buffer.String(); !strings.Contains(got, `"msg":"written","g.k":"v"}`) || strings.Contains(got, "not written") {
+		t.Errorf("production log = %q", got)
+	}
+	if !cmdtoolkit.NewSlogHandler(output.NewRecordingLogger()).Enabled(context.Background(), slog.LevelDebug-4) {
+		t.Errorf("Enabled() = false for a logger that cannot filter levels")
+	}
+}
+
+func newTextSlogLogger(b *bytes.Buffer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(b, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := cmdtoolkit.NewSlogLogger(newTextSlogLogger(buffer, cmdtoolkit.SlogLevelTrace))
+	logger.Trace("trace message", nil)
+	logger.Debug("debug message", map[string]any{"b": 2, "a": 1})
+	logger.Info("info message", map[string]any{"error": errors.New("oops")})
+	logger.Warning("warning message", nil)
+	logger.Error("error message", nil)
+	func() {
+		defer func() {
+			if r := recover(); r != "panic message" {
+				t.Errorf("Panic() recovered %v, want %q", r, "panic message")
+			}
+		}()
+		logger.Panic("panic message", nil)
+	}()
+	want := "" +
+		"level=DEBUG-4 msg=\"trace message\"\n" +
+		"level=DEBUG msg=\"debug message\" a=1 b=2\n" +
+		"level=INFO msg=\"info message\" error=oops\n" +
+		"level=WARN msg=\"warning message\"\n" +
+		"level=ERROR msg=\"error message\"\n" +
+		"level=ERROR+4 msg=\"panic message\"\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("NewSlogLogger() logged %q, want %q", got, want)
+	}
+}
+
+func TestNewSlogLogger_WillLog(t *testing.T) {
+	logger := cmdtoolkit.NewSlogLogger(newTextSlogLogger(&bytes.Buffer{}, slog.LevelWarn))
+	filter, ok := logger.(interface{ WillLog(output.Level) bool })
+	if !ok {
+		t.Fatalf("NewSlogLogger() does not implement WillLog")
+	}
+	tests := map[string]struct {
+		l    output.Level
+		want bool
+	}{
+		"trace":   {l: output.Trace, want: false},
+		"info":    {l: output.Info, want: false},
+		"warning": {l: output.Warning, want: true},
+		"fatal":   {l: output.Fatal, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := filter.WillLog(tt.l); got != tt.want {
+				t.Errorf("WillLog() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}